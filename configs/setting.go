@@ -42,7 +42,17 @@ type grpc struct {
 }
 
 type sms struct {
-	Token string `mapstructure:"token" json:"token" yaml:"token"`
+	Token  string `mapstructure:"token" json:"token" yaml:"token"`
+	Driver string `mapstructure:"driver" json:"driver" yaml:"driver"`    // http or grpc, http by default
+	Addr   string `mapstructure:"addr" json:"addr" yaml:"addr"`          // grpc address of open platform
+	SignID string `mapstructure:"sign_id" json:"sign_id" yaml:"sign_id"` // sms sign used by grpc
+	// templates of reschedule results for candidates without email, they are not notified if not set
+	RescheduleApprovedTemplateID uint `mapstructure:"reschedule_approved_template_id" json:"reschedule_approved_template_id" yaml:"reschedule_approved_template_id"`
+	RescheduleDeniedTemplateID   uint `mapstructure:"reschedule_denied_template_id" json:"reschedule_denied_template_id" yaml:"reschedule_denied_template_id"`
+}
+
+type email struct {
+	Addr string `mapstructure:"addr" json:"addr" yaml:"addr"` // grpc address of open platform, email is disabled if empty
 }
 
 type cos struct {
@@ -51,18 +61,49 @@ type cos struct {
 	CosSecretKey string `mapstructure:"cos_secret_key" json:"cos_secret_key" yaml:"cos_secret_key"`
 }
 
+type storage struct {
+	Driver string       `mapstructure:"driver" json:"driver" yaml:"driver"` // cos, local or s3, cos by default which uses the COS section
+	Local  localStorage `mapstructure:"local" json:"local" yaml:"local"`
+	S3     s3Storage    `mapstructure:"s3" json:"s3" yaml:"s3"`
+}
+
+type localStorage struct {
+	Root    string `mapstructure:"root" json:"root" yaml:"root"`             // directory to save files
+	BaseURL string `mapstructure:"base_url" json:"base_url" yaml:"base_url"` // address of backend in download urls
+	Secret  string `mapstructure:"secret" json:"secret" yaml:"secret"`       // key to sign download urls
+}
+
+type s3Storage struct {
+	Endpoint  string `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"` // like http://localhost:9000 for MinIO
+	Region    string `mapstructure:"region" json:"region" yaml:"region"`       // us-east-1 by default
+	Bucket    string `mapstructure:"bucket" json:"bucket" yaml:"bucket"`
+	AccessKey string `mapstructure:"access_key" json:"access_key" yaml:"access_key"`
+	SecretKey string `mapstructure:"secret_key" json:"secret_key" yaml:"secret_key"`
+}
+
+type upload struct {
+	MaxResumeSize          int64  `mapstructure:"max_resume_size" json:"max_resume_size" yaml:"max_resume_size"`                               // MB, 20 by default
+	MaxAnswerSize          int64  `mapstructure:"max_answer_size" json:"max_answer_size" yaml:"max_answer_size"`                               // MB, 100 by default
+	MaxRecruitmentFileSize int64  `mapstructure:"max_recruitment_file_size" json:"max_recruitment_file_size" yaml:"max_recruitment_file_size"` // MB, 50 by default
+	ClamAVAddr             string `mapstructure:"clamav_addr" json:"clamav_addr" yaml:"clamav_addr"`                                           // unix:///path or tcp://host:port of clamd, files are not scanned if empty
+	Quarantine             bool   `mapstructure:"quarantine" json:"quarantine" yaml:"quarantine"`                                              // save infected files under quarantine/ of storage
+}
+
 type apm struct {
 	Name          string `mapstructure:"name" json:"name" yaml:"name"`
 	ReportBackend string `mapstructure:"report_backend" json:"report_backend" yaml:"report_backend"`
 }
 
 type settings struct {
-	Server server `mapstructure:"server" yaml:"server"`
-	Pgsql  pgsql  `mapstructure:"pgsql" yaml:"pgsql"`
-	Redis  redis  `mapstructure:"redis" yaml:"redis"`
-	SSO    sso    `mapstructure:"sso" yaml:"sso"`
-	Grpc   grpc   `mapstructure:"grpc" yaml:"grpc"`
-	SMS    sms    `mapstructure:"sms" yaml:"sms"`
-	COS    cos    `mapstructure:"COS" yaml:"COS"`
-	Apm    apm    `mapstructure:"apm" yaml:"apm"`
+	Server  server  `mapstructure:"server" yaml:"server"`
+	Pgsql   pgsql   `mapstructure:"pgsql" yaml:"pgsql"`
+	Redis   redis   `mapstructure:"redis" yaml:"redis"`
+	SSO     sso     `mapstructure:"sso" yaml:"sso"`
+	Grpc    grpc    `mapstructure:"grpc" yaml:"grpc"`
+	SMS     sms     `mapstructure:"sms" yaml:"sms"`
+	Email   email   `mapstructure:"email" yaml:"email"`
+	COS     cos     `mapstructure:"COS" yaml:"COS"`
+	Storage storage `mapstructure:"storage" yaml:"storage"`
+	Upload  upload  `mapstructure:"upload" yaml:"upload"`
+	Apm     apm     `mapstructure:"apm" yaml:"apm"`
 }
@@ -2,7 +2,8 @@ package global
 
 func setup() {
 	setupPgsql()
-	setupCOS()
+	setupStorage()
+	setupUpload()
 	setupSess()
 	setupRedis()
 }
@@ -0,0 +1,32 @@
+package global
+
+import (
+	"UniqueRecruitmentBackend/configs"
+	"UniqueRecruitmentBackend/pkg/storage"
+	"fmt"
+)
+
+var store storage.Storage
+
+// GetStorage returns the object storage selected by configs
+func GetStorage() storage.Storage {
+	return store
+}
+
+func setupStorage() {
+	var err error
+	cfg := configs.Config.Storage
+	switch cfg.Driver {
+	case "", storage.DriverCOS:
+		store, err = storage.NewCOSStorage(configs.Config.COS.CosUrl, configs.Config.COS.CosSecretID, configs.Config.COS.CosSecretKey)
+	case storage.DriverLocal:
+		store, err = storage.NewLocalStorage(cfg.Local.Root, cfg.Local.BaseURL, cfg.Local.Secret)
+	case storage.DriverS3:
+		store, err = storage.NewS3Storage(cfg.S3.Endpoint, cfg.S3.Region, cfg.S3.Bucket, cfg.S3.AccessKey, cfg.S3.SecretKey)
+	default:
+		err = fmt.Errorf("storage driver %s is invalid", cfg.Driver)
+	}
+	if err != nil {
+		panic(fmt.Sprintf("setup storage rerror, %v", err))
+	}
+}
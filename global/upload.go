@@ -0,0 +1,45 @@
+package global
+
+import (
+	"UniqueRecruitmentBackend/configs"
+	"UniqueRecruitmentBackend/pkg/upload"
+	"fmt"
+)
+
+var validator *upload.Validator
+
+// GetValidator returns the validator of uploaded files configured by configs
+func GetValidator() *upload.Validator {
+	return validator
+}
+
+func setupUpload() {
+	cfg := configs.Config.Upload
+	policies := make(map[upload.Purpose]upload.Policy, len(upload.DefaultPolicies))
+	for purpose, policy := range upload.DefaultPolicies {
+		policies[purpose] = policy
+	}
+	for purpose, size := range map[upload.Purpose]int64{
+		upload.PurposeResume:          cfg.MaxResumeSize,
+		upload.PurposeAnswer:          cfg.MaxAnswerSize,
+		upload.PurposeRecruitmentFile: cfg.MaxRecruitmentFileSize,
+	} {
+		if size > 0 {
+			policy := policies[purpose]
+			policy.MaxSize = size << 20
+			policies[purpose] = policy
+		}
+	}
+	validator = &upload.Validator{Policies: policies}
+
+	if cfg.ClamAVAddr != "" {
+		scanner, err := upload.NewClamAVScanner(cfg.ClamAVAddr)
+		if err != nil {
+			panic(fmt.Sprintf("setup clamav rerror, %v", err))
+		}
+		validator.Scanner = scanner
+	}
+	if cfg.Quarantine {
+		validator.Quarantine = store
+	}
+}
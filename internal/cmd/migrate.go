@@ -16,12 +16,57 @@ var (
 				pkg.Application{},
 				pkg.Interview{},
 				pkg.Comment{},
+				pkg.ApplicationEvent{},
+				pkg.SMSMessage{},
+				pkg.RescheduleRequest{},
+				pkg.InterviewCancellation{},
+				pkg.Rubric{},
+				pkg.CommentRevision{},
+				pkg.EvaluationRound{},
+				pkg.Interviewer{},
+				pkg.ResumeVersion{},
 			)
 			if err != nil {
 				panic(err)
 			}
+
+			// trigram indexes for searching applications, which also work for ILIKE of chinese text
+			for _, sql := range searchIndexes {
+				if err = global.GetDB().Exec(sql).Error; err != nil {
+					panic(err)
+				}
+			}
+
+			// selections made before selectNumber was kept are counted, otherwise editing the slot number overbooks
+			if err = global.GetDB().Exec(recountInterviewSelections).Error; err != nil {
+				panic(err)
+			}
+
+			// resumes uploaded before versioning become the first version, see backfillResumeVersions
+			if err = global.GetDB().Exec(backfillResumeVersions).Error; err != nil {
+				panic(err)
+			}
 		},
 	}
+
+	searchIndexes = []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_applications_intro_trgm ON applications USING gin ((COALESCE(intro, '')) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_applications_institute_trgm ON applications USING gin ((COALESCE(institute, '')) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_applications_major_trgm ON applications USING gin ((COALESCE(major, '')) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_applications_referrer_trgm ON applications USING gin ((COALESCE(referrer, '')) gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_comments_content_trgm ON comments USING gin (content gin_trgm_ops)`,
+	}
+
+	recountInterviewSelections = `UPDATE interviews SET "selectNumber" =
+		(SELECT COUNT(*) FROM interview_selections WHERE interview_selections.interview_uid = interviews.uid)`
+
+	// The upload time of these resumes isn't recorded, the creation of application is taken as an approximation.
+	// updatedAt can't be used as it changes on every later write like step changes, which would place
+	// most resumes after the deadline of recruitment
+	backfillResumeVersions = `INSERT INTO resume_versions ("applicationId", version, key, "createdAt", "updatedAt")
+		SELECT a.uid, 1, a.resume, a."createdAt", a."createdAt" FROM applications a
+		WHERE a.resume <> '' AND NOT EXISTS (SELECT 1 FROM resume_versions v WHERE v."applicationId" = a.uid)`
 )
 
 func init() {
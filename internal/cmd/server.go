@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"UniqueRecruitmentBackend/configs"
+	"UniqueRecruitmentBackend/internal/jobs"
 	"UniqueRecruitmentBackend/internal/router"
 	"UniqueRecruitmentBackend/internal/tracer"
+	"UniqueRecruitmentBackend/pkg/email"
+	"UniqueRecruitmentBackend/pkg/sms"
 	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/xylonx/zapx"
@@ -47,6 +50,18 @@ func runServer() {
 		WriteTimeout: configs.Config.Server.WriteTimeout * time.Minute,
 	}
 
+	if err := sms.Setup(); err != nil {
+		zapx.Fatal("setup sms sender failed", zap.Error(err))
+	}
+	if err := email.Setup(); err != nil {
+		zapx.Fatal("setup email sender failed", zap.Error(err))
+	}
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go jobs.RunSMSWorker(jobsCtx)
+	go jobs.RunStorageSweeper(jobsCtx)
+
 	go func() {
 		if err := s.ListenAndServe(); err != nil {
 			log.Println(err)
@@ -31,6 +31,14 @@ func IsAdmin(c *gin.Context) bool {
 	return getValue(c, "role") == string(pkg.Admin)
 }
 
+// GetOperator get the user who sends the request
+func GetOperator(c *gin.Context) *pkg.Operator {
+	return &pkg.Operator{
+		UID:  GetUID(c),
+		Role: pkg.Role(getValue(c, "role")),
+	}
+}
+
 func GetUID(c *gin.Context) string {
 	return getValue(c, "X-UID")
 }
@@ -3,8 +3,10 @@ package controllers
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"net/url"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +18,7 @@ import (
 	"UniqueRecruitmentBackend/internal/utils"
 	"UniqueRecruitmentBackend/pkg"
 	"UniqueRecruitmentBackend/pkg/grpc"
+	"UniqueRecruitmentBackend/pkg/upload"
 )
 
 // CreateApplication create application.
@@ -57,21 +60,30 @@ func CreateApplication(c *gin.Context) {
 	}
 
 	uid := common.GetUID(c)
-	filePath := ""
+	var resume *pkg.ResumeVersion
 	if opts.Resume != nil {
-		// file path example: 2023秋(rname)/web(group)/wwb(uid)/filename
-		filePath = fmt.Sprintf("%s/%s/%s/%s", r.Name, opts.Group, uid, opts.Resume.Filename)
+		var result *upload.Result
+		if result, err = validateFile(c, upload.PurposeResume, opts.Resume); err != nil {
+			return
+		}
+		// file path example: 2023秋(rname)/web(group)/wwb(uid)/{sha256}.pdf
+		// the resume is saved under the group after update
+		keyApp := *app
+		if opts.Group != "" {
+			keyApp.Group = opts.Group
+		}
+		resume = newResumeVersion(uploadKeyPrefix(r, &keyApp)+result.Filename(), result)
 	}
 
 	//save application to database
-	app, err = models.CreateApplication(opts, uid, filePath)
+	app, err = models.CreateApplication(opts, uid, resume)
 	return
 }
 
 // GetApplication get application.
 // @Id get_application.
 // @Summary get an application for candidate and member
-// @Description get candidate's application by applicationId, candidate and member will see different views of application
+// @Description get candidate's application by applicationId, candidate and member will see different views of application, member only sees the comments visible to them
 // @Tags application
 // @Accept  json
 // @Produce  json
@@ -102,6 +114,11 @@ func GetApplication(c *gin.Context) {
 		}
 	} else {
 		app, err = models.GetApplicationById(aid)
+		if err == nil {
+			apps := []pkg.Application{*app}
+			err = filterComments(c, apps)
+			app = &apps[0]
+		}
 	}
 
 	if err != nil {
@@ -168,12 +185,21 @@ func UpdateApplication(c *gin.Context) {
 		return
 	}
 
-	filePath := ""
+	var resume *pkg.ResumeVersion
 	if opts.Resume != nil {
-		filePath = fmt.Sprintf("%s/%s/%s/%s", r.Name, opts.Group, uid, opts.Resume.Filename)
+		var result *upload.Result
+		if result, err = validateFile(c, upload.PurposeResume, opts.Resume); err != nil {
+			return
+		}
+		// the resume is saved under the group after update
+		keyApp := *app
+		if opts.Group != "" {
+			keyApp.Group = opts.Group
+		}
+		resume = newResumeVersion(uploadKeyPrefix(r, &keyApp)+result.Filename(), result)
 	}
 
-	app, err = models.UpdateApplication(opts, filePath, "")
+	app, err = models.UpdateApplication(opts, resume, "")
 	return
 }
 
@@ -212,7 +238,7 @@ func DeleteApplication(c *gin.Context) {
 		err = errors.New("you can't delete other's application")
 		return
 	}
-	err = models.DeleteApplication(aid)
+	err = models.DeleteApplication(aid, common.GetOperator(c))
 	return
 }
 
@@ -229,8 +255,9 @@ func DeleteApplication(c *gin.Context) {
 // @Router /applications/{aid}/abandoned [put]
 func AbandonApplication(c *gin.Context) {
 	var (
-		app *pkg.Application
-		err error
+		app  *pkg.Application
+		opts *pkg.ChangeAppStatusOpts
+		err  error
 	)
 	defer func() { common.Resp(c, nil, err) }()
 
@@ -239,6 +266,9 @@ func AbandonApplication(c *gin.Context) {
 		err = fmt.Errorf("request param error, application id is nil")
 		return
 	}
+	if opts, err = bindChangeAppStatusOpts(c); err != nil {
+		return
+	}
 
 	uid := common.GetUID(c)
 	app, err = models.GetApplicationByIdForCandidate(aid)
@@ -251,7 +281,7 @@ func AbandonApplication(c *gin.Context) {
 		return
 	}
 
-	err = models.AbandonApplication(aid)
+	err = models.AbandonApplication(aid, opts.Reason, common.GetOperator(c))
 	return
 }
 
@@ -305,13 +335,18 @@ func UploadAnswerFile(c *gin.Context) {
 		return
 	}
 
+	result, err := validateFile(c, upload.PurposeAnswer, opts.File)
+	if err != nil {
+		return
+	}
+
 	app_opts := &pkg.UpdateAppOpts{}
 	app_opts.Answer = opts.File
 	app_opts.Aid = opts.Aid
 
-	// file path example: 2023秋(rname)/web(group)/wwb(uid)/filename
-	filePath := fmt.Sprintf("%s/%s/%s/%s", r.Name, app.Group, app.CandidateID, opts.File.Filename)
-	_, err = models.UpdateApplication(app_opts, "", filePath)
+	// file path example: 2023秋(rname)/web(group)/wwb(uid)/{sha256}.zip
+	filePath := fmt.Sprintf("%s/%s/%s/%s", r.Name, app.Group, app.CandidateID, result.Filename())
+	_, err = models.UpdateApplication(app_opts, nil, filePath)
 	return
 }
 
@@ -377,17 +412,14 @@ func DownloadAnswerFile(c *gin.Context) {
 	}
 
 	// file path example: 2023秋(rname)/web(group)/wwb(uid)/filename
-	resp, err := global.GetCOSObjectResp(app.Answer)
+	obj, err := global.GetStorage().Get(c.Request.Context(), app.Answer)
 	if err != nil {
 		common.Resp(c, nil, err)
 		return
 	}
+	defer obj.Body.Close()
 
-	reader := resp.Body
-	contentLength := resp.ContentLength
-	contentType := resp.Header.Get("Content-Type")
-
-	c.DataFromReader(http.StatusOK, contentLength, contentType, reader, nil)
+	c.DataFromReader(http.StatusOK, obj.ContentLength, obj.ContentType, obj.Body, nil)
 }
 
 // RejectApplication reject application.
@@ -403,7 +435,8 @@ func DownloadAnswerFile(c *gin.Context) {
 // @Router /applications/{aid}/abandoned [put]
 func RejectApplication(c *gin.Context) {
 	var (
-		err error
+		opts *pkg.ChangeAppStatusOpts
+		err  error
 	)
 	defer func() { common.Resp(c, nil, err) }()
 
@@ -412,6 +445,9 @@ func RejectApplication(c *gin.Context) {
 		err = fmt.Errorf("request param error, application id is nil")
 		return
 	}
+	if opts, err = bindChangeAppStatusOpts(c); err != nil {
+		return
+	}
 
 	uid := common.GetUID(c)
 
@@ -420,18 +456,83 @@ func RejectApplication(c *gin.Context) {
 		return
 	}
 
-	err = models.RejectApplication(aid)
+	err = models.RejectApplication(aid, opts.Reason, common.GetOperator(c))
+	return
+}
+
+// RestoreApplication restore application.
+// @Id restore_application.
+// @Summary restore abandoned/rejected application by applicationId
+// @Description restore abandoned/rejected application by applicationId, rejected application can be restored by member of the corresponding group, abandoned application can only be restored by admin
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param	aid path int true "application id"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/restored [put]
+func RestoreApplication(c *gin.Context) {
+	var (
+		opts *pkg.ChangeAppStatusOpts
+		err  error
+	)
+	defer func() { common.Resp(c, nil, err) }()
+
+	aid := c.Param("aid")
+	if aid == "" {
+		err = fmt.Errorf("request param error, application id is nil")
+		return
+	}
+	if opts, err = bindChangeAppStatusOpts(c); err != nil {
+		return
+	}
+
+	// check member's role to restore application
+	if err = checkMemberGroup(aid, common.GetUID(c)); err != nil {
+		return
+	}
+
+	err = models.RestoreApplication(aid, opts.Reason, common.GetOperator(c))
+	return
+}
+
+// GetApplicationHistory get application's history.
+// @Id get_application_history.
+// @Summary get the history of application by applicationId
+// @Description get who changed the application and how (step, rejection, interview time...), the earliest first, can only be got by member
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param	aid path int true "application id"
+// @Success 200 {object} common.JSONResult{data=[]pkg.ApplicationEvent} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/history [get]
+func GetApplicationHistory(c *gin.Context) {
+	var (
+		events []pkg.ApplicationEvent
+		err    error
+	)
+	defer func() { common.Resp(c, events, err) }()
+
+	aid := c.Param("aid")
+	if aid == "" {
+		err = fmt.Errorf("request param error, application id is nil")
+		return
+	}
+
+	events, err = models.GetApplicationEvents(aid)
 	return
 }
 
 // GetResume get application's resume.
 // @Id get_resume.
 // @Summary get application's resume by applicationId
-// @Description get application's resume by applicationId, can only be got by member or application's owner
+// @Description get application's resume by applicationId, the latest version by default, can only be got by member or application's owner
 // @Tags application
 // @Accept  json
 // @Produce  json
 // @Param	aid path int true "application id"
+// @Param	version query int false "version of resume, the latest if omitted"
 // @Success 200 {object} common.JSONResult{} ""
 // @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
 // @Router /applications/{aid}/resume [get]
@@ -441,13 +542,17 @@ func GetResume(c *gin.Context) {
 		err error
 	)
 
-	aid := c.Param("aid")
-	if aid == "" {
-		err = fmt.Errorf("request param error, application id is nil")
+	opts := &pkg.GetResumeOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		common.Resp(c, nil, err)
+		return
+	}
+	if err = c.ShouldBindQuery(opts); err != nil {
+		common.Resp(c, nil, err)
 		return
 	}
 
-	app, err = models.GetApplicationByIdForCandidate(aid)
+	app, err = models.GetApplicationByIdForCandidate(opts.Aid)
 	if err != nil {
 		common.Resp(c, nil, err)
 		return
@@ -459,33 +564,90 @@ func GetResume(c *gin.Context) {
 		common.Resp(c, nil, err)
 		return
 	}
-	if app.Resume == "" {
+
+	key := app.Resume
+	if opts.Version != 0 {
+		var version *pkg.ResumeVersion
+		if version, err = models.GetResumeVersion(app.Uid, opts.Version); err != nil {
+			common.Resp(c, nil, err)
+			return
+		}
+		key = version.Key
+	}
+	if key == "" {
 		err = fmt.Errorf("you don't upload resume")
 		common.Resp(c, nil, err)
 		return
 	}
 
-	resp, err := global.GetCOSObjectResp(app.Resume)
+	obj, err := global.GetStorage().Get(c.Request.Context(), key)
 	if err != nil {
 		common.Resp(c, nil, err)
 		return
 	}
+	defer obj.Body.Close()
+
+	c.DataFromReader(http.StatusOK, obj.ContentLength, obj.ContentType, obj.Body, nil)
+}
+
+// GetResumeVersions get all versions of application's resume.
+// @Id get_resume_versions.
+// @Summary get all versions of application's resume
+// @Description get all versions of application's resume with their upload time and hash, the earliest first. deadline_version is the version which existed at the deadline of recruitment. Can only be got by member or application's owner
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param	aid path string true "application id"
+// @Success 200 {object} common.JSONResult{data=pkg.GetResumeVersionsResp} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/resume/versions [get]
+func GetResumeVersions(c *gin.Context) {
+	var (
+		app  *pkg.Application
+		r    *pkg.Recruitment
+		resp *pkg.GetResumeVersionsResp
+		err  error
+	)
+	defer func() { common.Resp(c, resp, err) }()
+
+	aid := c.Param("aid")
+	if aid == "" {
+		err = fmt.Errorf("request param error, application id is nil")
+		return
+	}
 
-	reader := resp.Body
-	contentLength := resp.ContentLength
-	contentType := resp.Header.Get("Content-Type")
+	app, err = models.GetApplicationByIdForCandidate(aid)
+	if err != nil {
+		return
+	}
+	if !common.IsMember(c) && !(app.CandidateID == common.GetUID(c)) {
+		err = fmt.Errorf("you don't have role to get the resumes")
+		return
+	}
 
-	c.DataFromReader(http.StatusOK, contentLength, contentType, reader, nil)
+	r, err = models.GetRecruitmentById(app.RecruitmentID)
+	if err != nil {
+		return
+	}
+	versions, err := models.GetResumeVersions(aid)
+	if err != nil {
+		return
+	}
+	resp = &pkg.GetResumeVersionsResp{
+		Versions:        versions,
+		DeadlineVersion: pkg.DeadlineVersion(versions, r.Deadline),
+	}
 }
 
 // GetAllApplications get all applications by recruitmentId.
 // @Id get_all_applications.
 // @Summary get all applications by recruitmentId.
-// @Description get all applications by recruitmentId, can only be got by member, applications information included comments and interview selections.
+// @Description get all applications by recruitmentId, can only be got by member, applications information included comments and interview selections. Sort by score to rank applications by the average rubric score, the highest first
 // @Tags application
 // @Accept  json
 // @Produce  json
 // @Param	aid path int true "application id"
+// @Param	sort query string false "score"
 // @Success 200 {object} common.JSONResult{data=[]pkg.Application} ""
 // @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
 // @Router /applications/recruitment/{rid} [get]
@@ -506,22 +668,144 @@ func GetAllApplications(c *gin.Context) {
 	if err != nil {
 		return
 	}
+	utils.FillApplicationsUserDetail(c, apps)
 
-	if len(apps) == 0 {
+	if err = filterComments(c, apps); err != nil {
 		return
 	}
 
-	// todo wwb
-	// add grpc handler(get all user details)
-	//var userIds []string
-	//for _, app := range apps {
-	//	userIds = append(userIds, app.CandidateID)
-	//}
-	for i := range apps {
-		apps[i].UserDetail, err = grpc.GetUserInfoByUID(apps[i].CandidateID)
+	// scores are filled by GetApplicationsByRid
+	if c.Query("sort") == pkg.SortByScore {
+		sort.SliceStable(apps, func(i, j int) bool {
+			if apps[i].Score == nil || apps[j].Score == nil {
+				return apps[j].Score == nil && apps[i].Score != nil
+			}
+			return *apps[i].Score > *apps[j].Score
+		})
+	}
+	return
+}
+
+// ListApplications list applications of recruitment by page.
+// @Id list_applications.
+// @Summary list applications of recruitment by page with filters.
+// @Description list applications of recruitment by page with filters and sort, can only be got by member. Comments and interview selections are not included, get them by get_application. Pass next_cursor of the last page as cursor to get the next page
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param	query query pkg.ListApplicationsOpts false "filters, sort and page"
+// @Success 200 {object} common.JSONResult{data=pkg.ListApplicationsResp} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/applications [get]
+func ListApplications(c *gin.Context) {
+	var (
+		resp   *pkg.ListApplicationsResp
+		cursor *pkg.ApplicationCursor
+		err    error
+	)
+	defer func() { common.Resp(c, resp, err) }()
+
+	opts := &pkg.ListApplicationsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindQuery(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	viewer, err := getCommentViewer(c)
+	if err != nil {
+		return
+	}
+	var apps []pkg.Application
+	apps, cursor, err = models.ListApplications(opts, viewer)
+	if err != nil {
+		return
+	}
+
+	utils.FillApplicationsUserDetail(c, apps)
+
+	resp = &pkg.ListApplicationsResp{Applications: apps}
+	if cursor != nil {
+		resp.NextCursor = cursor.Encode()
+	}
+	return
+}
+
+// SearchApplications search applications of recruitment.
+// @Id search_applications.
+// @Summary search applications of recruitment.
+// @Description search applications by intro, institute, major, referrer, comments and the name and phone of candidate, ranked by the matched fields. Member can only find applications of his/her groups, admin can find all
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param	q query string true "keyword"
+// @Param	limit query int false "20 by default, 100 at most"
+// @Success 200 {object} common.JSONResult{data=[]pkg.Application} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/applications/search [get]
+func SearchApplications(c *gin.Context) {
+	var (
+		apps       []pkg.Application
+		member     *pkg.UserDetail
+		candidates map[string]string
+		err        error
+	)
+	defer func() { common.Resp(c, apps, err) }()
+
+	opts := &pkg.SearchApplicationsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindQuery(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	// nil groups means all groups
+	var groups []string
+	viewer := &pkg.CommentViewer{UID: common.GetUID(c), IsAdmin: common.IsAdmin(c)}
+	if !viewer.IsAdmin {
+		member, err = grpc.GetUserInfoByUID(viewer.UID)
 		if err != nil {
 			return
 		}
+		groups = append([]string{}, member.Groups...)
+		viewer.Groups = member.Groups
+	}
+
+	// name and phone are kept by sso, so they are matched here with the cached users
+	candidates, err = models.GetCandidateIDsByRid(opts.Rid, groups)
+	if err != nil {
+		return
+	}
+	uids := make([]string, 0, len(candidates))
+	for _, uid := range candidates {
+		uids = append(uids, uid)
+	}
+	users := utils.GetUsersByUIDs(c, uids)
+	q := strings.ToLower(opts.Q)
+	var userMatched []string
+	for aid, uid := range candidates {
+		user := users[uid]
+		if strings.Contains(strings.ToLower(user.Name), q) || strings.Contains(user.Phone, q) {
+			userMatched = append(userMatched, aid)
+		}
+	}
+
+	apps, err = models.SearchApplications(opts.Rid, opts.Q, groups, userMatched, opts.Limit, viewer)
+	if err != nil {
+		return
+	}
+	for i := range apps {
+		apps[i].UserDetail = users[apps[i].CandidateID]
 	}
 	return
 }
@@ -529,7 +813,7 @@ func GetAllApplications(c *gin.Context) {
 // SetApplicationStep set application step by applicationId.
 // @Id set_application_step.
 // @Summary set application step by applicationId.
-// @Description get all applications by recruitmentId, can only be modified by member of the corresponding group
+// @Description set application step by applicationId, can only be modified by member of the corresponding group. Application moves forward one step each time, only quick pass application can skip steps and only admin can move it back
 // @Tags application
 // @Accept  json
 // @Produce  json
@@ -560,7 +844,75 @@ func SetApplicationStep(c *gin.Context) {
 		return
 	}
 
-	err = models.SetApplicationStepById(opts)
+	err = models.SetApplicationStepById(opts, common.GetOperator(c))
+	return
+}
+
+// SetApplicationsStep set applications step in batch.
+// @Id set_applications_step.
+// @Summary set step of applications in batch.
+// @Description move applications from one step to another in batch, can only be modified by member of the corresponding group. All applications are changed or none of them is changed
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param pkg.SetAppsStepOpts body pkg.SetAppsStepOpts true "applications id and step"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty, msg lists every refused application"
+// @Router /applications/step [put]
+func SetApplicationsStep(c *gin.Context) {
+	var (
+		err error
+	)
+	defer func() { common.Resp(c, nil, err) }()
+
+	opts := &pkg.SetAppsStepOpts{}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	uid := common.GetUID(c)
+	if err = checkMemberGroups(opts.Aids, uid); err != nil {
+		return
+	}
+
+	err = models.SetApplicationsStep(opts, common.GetOperator(c))
+	return
+}
+
+// RejectApplications reject applications in batch.
+// @Id reject_applications.
+// @Summary reject applications in batch.
+// @Description reject applications in batch, can only be rejected by member of the corresponding group. All applications are rejected or none of them is rejected
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param pkg.RejectAppsOpts body pkg.RejectAppsOpts true "applications id and reason"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty, msg lists every refused application"
+// @Router /applications/rejected [put]
+func RejectApplications(c *gin.Context) {
+	var (
+		err error
+	)
+	defer func() { common.Resp(c, nil, err) }()
+
+	opts := &pkg.RejectAppsOpts{}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	uid := common.GetUID(c)
+	if err = checkMemberGroups(opts.Aids, uid); err != nil {
+		return
+	}
+
+	err = models.RejectApplications(opts, common.GetOperator(c))
 	return
 }
 
@@ -620,14 +972,14 @@ func SetApplicationInterviewTime(c *gin.Context) {
 		return
 	}
 
-	err = models.SetApplicationInterviewTime(opts)
+	err = models.SetApplicationInterviewTime(opts, common.GetOperator(c))
 	return
 }
 
 // GetInterviewsSlots set_application_interview_time
 // @Id set_application_interview_time.
 // @Summary allocate application's group/team interview time.
-// @Description allocate application's group/team interview time, can only be modified by member of the corresponding group
+// @Description get the group/team interviews candidate can select, remaining is the number of seats left (omitted if the interview is unlimited)
 // @Tags application
 // @Accept  json
 // @Produce  json
@@ -640,7 +992,6 @@ func GetInterviewsSlots(c *gin.Context) {
 	var (
 		interviews []pkg.Interview
 		app        *pkg.Application
-		r          *pkg.Recruitment
 		err        error
 	)
 	defer func() { common.Resp(c, interviews, err) }()
@@ -658,11 +1009,6 @@ func GetInterviewsSlots(c *gin.Context) {
 		return
 	}
 
-	r, err = models.GetFullRecruitmentById(app.RecruitmentID)
-	if err != nil {
-		return
-	}
-
 	var name pkg.Group
 	if opts.InterviewType == pkg.InGroup {
 		name = app.Group
@@ -670,11 +1016,8 @@ func GetInterviewsSlots(c *gin.Context) {
 		name = pkg.Unique
 	}
 
-	for _, interview := range r.Interviews {
-		if interview.Name == name {
-			interviews = append(interviews, interview)
-		}
-	}
+	// remaining seats are filled by model
+	interviews, err = models.GetInterviewsByRidAndNameWithoutApp(app.RecruitmentID, name)
 	return
 }
 
@@ -722,18 +1065,13 @@ func GetResumeUrl(c *gin.Context) {
 		return
 	}
 
-	resumeUrl := &url.URL{}
-	resumeUrl, err = global.GetCOSObjectURL(app.Resume)
-	if err != nil {
-		return
-	}
-	resp.ResumeUrl = resumeUrl.String()
+	resp.ResumeUrl, err = global.GetStorage().Presign(c.Request.Context(), app.Resume, time.Hour)
 }
 
 // SelectInterviewSlots select interview slots
 // @Id select_interview_slots.
 // @Summary candidate select group/team interview time.
-// @Description candidate select group/team interview time, to save time, this api will not check Whether slot number exceeds the limit
+// @Description candidate select group/team interview time, selecting a full interview fails and none of the selections are saved
 // @Tags application
 // @Accept  json
 // @Produce  json
@@ -785,7 +1123,7 @@ func SelectInterviewSlots(c *gin.Context) {
 		return
 	}
 
-	if err = checkStepInInterviewSelectStatus(opts.InterviewType, app); err != nil {
+	if err = checkStepInInterviewSelectStatus(opts.InterviewType, app, r.GetPipeline()); err != nil {
 		return
 	}
 
@@ -850,13 +1188,23 @@ func checkApplyStatus(application *pkg.Application) error {
 	return nil
 }
 
-// check if application step is in interview select status
-func checkStepInInterviewSelectStatus(interviewType pkg.GroupOrTeam, app *pkg.Application) error {
-	if interviewType == pkg.InGroup && app.Step != pkg.GroupTimeSelection {
-		return fmt.Errorf("you can't set group interview time now")
+// check if application step is in interview select status of the recruitment pipeline
+func checkStepInInterviewSelectStatus(interviewType pkg.GroupOrTeam, app *pkg.Application, pipeline pkg.Pipeline) error {
+	if interviewType == pkg.InGroup {
+		if !pipeline.Contains(pkg.GroupTimeSelection) {
+			return fmt.Errorf("there is no group interview time selection in this recruitment")
+		}
+		if app.Step != pkg.GroupTimeSelection {
+			return fmt.Errorf("you can't set group interview time now")
+		}
 	}
-	if interviewType == pkg.InTeam && app.Step != pkg.TeamTimeSelection {
-		return fmt.Errorf("you can't set team interview time now")
+	if interviewType == pkg.InTeam {
+		if !pipeline.Contains(pkg.TeamTimeSelection) {
+			return fmt.Errorf("there is no team interview time selection in this recruitment")
+		}
+		if app.Step != pkg.TeamTimeSelection {
+			return fmt.Errorf("you can't set team interview time now")
+		}
 	}
 	return nil
 }
@@ -881,6 +1229,40 @@ func checkMemberGroup(aid string, uid string) (err error) {
 		"and you cannot manipulate other people’s application. ")
 }
 
+// checkMemberGroups is checkMemberGroup for applications in batch,
+// the error lists every application out of member's groups
+func checkMemberGroups(aids []string, uid string) error {
+	apps, err := models.GetApplicationsByIds(aids)
+	if err != nil {
+		return err
+	}
+
+	member, err := grpc.GetUserInfoByUID(uid)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for _, app := range apps {
+		if !utils.CheckInGroups(member.Groups, app.Group) {
+			errs = append(errs, fmt.Sprintf("you and the candidate of application %s are not in the same group", app.Uid))
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// bindChangeAppStatusOpts bind the optional body of reject/abandon/restore application
+func bindChangeAppStatusOpts(c *gin.Context) (*pkg.ChangeAppStatusOpts, error) {
+	opts := &pkg.ChangeAppStatusOpts{}
+	if err := c.ShouldBindJSON(opts); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return opts, nil
+}
+
 func getAddAndDelInterviews(originInterviews []pkg.Interview, selectInterviews []pkg.Interview) (iidsToAdd []string, iidsToDel []string) {
 	for i := range originInterviews {
 		ok := false
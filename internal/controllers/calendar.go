@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/internal/utils"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/ical"
+)
+
+const calendarUIDDomain = "hr.hustunique.com"
+
+// GetApplicationCalendar get the interviews of application as iCalendar.
+// @Id get_application_calendar.
+// @Summary get the allocated interviews of application as iCalendar
+// @Description get the allocated group/team interviews of application as iCalendar, the event of each type keeps its uid after rescheduling so that it replaces the old one, and is cancelled when the allocation is cleared or the interview is deleted. Can only be got by application's owner or member of the corresponding group
+// @Tags calendar
+// @Produce  text/calendar
+// @Param	aid path string true "application id"
+// @Success 200 {string} string "iCalendar"
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/interviews.ics [get]
+func GetApplicationCalendar(c *gin.Context) {
+	var (
+		app *pkg.Application
+		r   *pkg.Recruitment
+		err error
+	)
+	defer func() {
+		if err != nil {
+			common.Resp(c, nil, err)
+		}
+	}()
+
+	aid := c.Param("aid")
+	app, err = models.GetApplicationByIdForCandidate(aid)
+	if err != nil {
+		return
+	}
+
+	uid := common.GetUID(c)
+	if common.IsCandidate(c) {
+		if app.CandidateID != uid {
+			err = errors.New("for candidate,you can't see other's interviews")
+			return
+		}
+	} else if err = checkMemberGroup(aid, uid); err != nil {
+		return
+	}
+
+	r, err = models.GetRecruitmentById(app.RecruitmentID)
+	if err != nil {
+		return
+	}
+	recruitmentName := utils.ConvertRecruitmentName(r.Name)
+
+	cal := &ical.Calendar{Name: fmt.Sprintf("%s面试", recruitmentName)}
+	allocations := []struct {
+		interviewType pkg.GroupOrTeam
+		iid           string
+		interview     *pkg.Interview
+	}{
+		{pkg.InGroup, app.InterviewAllocationsGroupId, &app.InterviewAllocationsGroup},
+		{pkg.InTeam, app.InterviewAllocationsTeamId, &app.InterviewAllocationsTeam},
+	}
+	for _, allocation := range allocations {
+		eventUID := fmt.Sprintf("application-%s-%s@%s", app.Uid, allocation.interviewType, calendarUIDDomain)
+		// the interview is not loaded if it has been deleted
+		if allocation.iid == "" || allocation.interview.Uid == "" {
+			var cancellation *pkg.InterviewCancellation
+			cancellation, err = models.GetAllocationCancellation(app.Uid, allocation.interviewType)
+			if err != nil {
+				return
+			}
+			if cancellation == nil {
+				continue
+			}
+			cal.Events = append(cal.Events, ical.Event{
+				UID:       eventUID,
+				Sequence:  cancellation.CreatedAt.Unix(),
+				Summary:   fmt.Sprintf("%s %s", recruitmentName, interviewTitle(cancellation.Name)),
+				Start:     cancellation.Start,
+				End:       cancellation.End,
+				Modified:  cancellation.CreatedAt,
+				Cancelled: true,
+			})
+			continue
+		}
+
+		// the event changes if the application is moved to another interview or the interview is updated
+		modified := latest(app.UpdatedAt, allocation.interview.UpdatedAt)
+		cal.Events = append(cal.Events, ical.Event{
+			UID:      eventUID,
+			Sequence: modified.Unix(),
+			Summary:  fmt.Sprintf("%s %s", recruitmentName, interviewTitle(allocation.interview.Name)),
+			Start:    allocation.interview.Start,
+			End:      allocation.interview.End,
+			Modified: modified,
+		})
+	}
+
+	writeCalendar(c, cal, "interviews.ics")
+}
+
+// GetRecruitmentCalendar get the interviews of group as iCalendar.
+// @Id get_recruitment_calendar.
+// @Summary get the interviews of group as iCalendar
+// @Description get the interviews of group (or unique) as iCalendar, deleted interviews are kept as cancelled events
+// @Tags calendar
+// @Produce  text/calendar
+// @Param	rid path string true "recruitment id"
+// @Param 	name path pkg.Group true "pkg.Group"
+// @Success 200 {string} string "iCalendar"
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/interviews/{name}/calendar.ics [get]
+func GetRecruitmentCalendar(c *gin.Context) {
+	var (
+		r             *pkg.Recruitment
+		interviews    []pkg.Interview
+		cancellations []pkg.InterviewCancellation
+		err           error
+	)
+	defer func() {
+		if err != nil {
+			common.Resp(c, nil, err)
+		}
+	}()
+
+	opts := &pkg.GetInterviewsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	r, err = models.GetRecruitmentById(opts.Rid)
+	if err != nil {
+		return
+	}
+	interviews, err = models.GetInterviewsByRidAndNameWithoutApp(opts.Rid, opts.Name)
+	if err != nil {
+		return
+	}
+	cancellations, err = models.GetInterviewCancellations(opts.Rid, opts.Name)
+	if err != nil {
+		return
+	}
+
+	recruitmentName := utils.ConvertRecruitmentName(r.Name)
+	summary := fmt.Sprintf("%s %s", recruitmentName, interviewTitle(opts.Name))
+	cal := &ical.Calendar{Name: summary}
+	for _, interview := range interviews {
+		description := fmt.Sprintf("已选择 %d 人", interview.SelectNumber)
+		if interview.SlotNumber > 0 {
+			description = fmt.Sprintf("已选择 %d/%d 人", interview.SelectNumber, interview.SlotNumber)
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         interviewEventUID(interview.Uid),
+			Sequence:    interview.UpdatedAt.Unix(),
+			Summary:     summary,
+			Description: description,
+			Start:       interview.Start,
+			End:         interview.End,
+			Modified:    interview.UpdatedAt,
+		})
+	}
+	for _, cancellation := range cancellations {
+		cal.Events = append(cal.Events, ical.Event{
+			UID:       interviewEventUID(cancellation.InterviewID),
+			Sequence:  cancellation.CreatedAt.Unix(),
+			Summary:   summary,
+			Start:     cancellation.Start,
+			End:       cancellation.End,
+			Modified:  cancellation.CreatedAt,
+			Cancelled: true,
+		})
+	}
+
+	writeCalendar(c, cal, fmt.Sprintf("%s.ics", opts.Name))
+}
+
+func writeCalendar(c *gin.Context, cal *ical.Calendar, filename string) {
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", cal.Marshal())
+}
+
+func interviewEventUID(iid string) string {
+	return fmt.Sprintf("interview-%s@%s", iid, calendarUIDDomain)
+}
+
+func interviewTitle(name pkg.Group) string {
+	if name == pkg.Unique {
+		return pkg.EnToZhStepMap[pkg.TeamInterview]
+	}
+	return fmt.Sprintf("%s组%s", name, pkg.EnToZhStepMap[pkg.GroupInterview])
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -14,7 +14,7 @@ import (
 // CreateComment create comment
 // @Id create_comment.
 // @Summary create comment for application
-// @Description create comment for applications, only can be created by member.
+// @Description create comment for applications, only can be created by member. Scores are checked against the rubric of application's group, and weighted into the score of comment. Reply to a comment by parent_id, visibility is group, members (default) or admins
 // @Tags comment
 // @Accept  json
 // @Produce  json
@@ -25,6 +25,7 @@ import (
 func CreateComment(c *gin.Context) {
 	var (
 		comment *pkg.Comment
+		app     *pkg.Application
 		user    *pkg.UserDetail
 		err     error
 	)
@@ -41,6 +42,26 @@ func CreateComment(c *gin.Context) {
 		return
 	}
 
+	app, err = models.GetApplicationById(opts.ApplicationID)
+	if err != nil {
+		return
+	}
+	opts.Step = app.Step
+
+	if opts.ParentID != "" {
+		if err = checkCommentParent(c, opts, app); err != nil {
+			return
+		}
+	}
+	if opts.Visibility == "" {
+		opts.Visibility = pkg.CommentVisibleToMembers
+	}
+	if len(opts.Scores) != 0 {
+		if opts.Score, err = scoreComment(app, opts.Scores); err != nil {
+			return
+		}
+	}
+
 	user, err = grpc.GetUserInfoByUID(uid)
 	if err != nil {
 		return
@@ -93,3 +114,214 @@ func DeleteComment(c *gin.Context) {
 	err = models.DeleteCommentById(cid)
 	return
 }
+
+// UpdateComment update comment
+// @Id update_comment.
+// @Summary update comment of application
+// @Description replace the content, evaluation, scores or visibility of comment, the fields not given are unchanged. The comment before editing is kept in its revisions. Only can be updated by comment's owner.
+// @Tags comment
+// @Accept  json
+// @Produce  json
+// @Param 	cid path string true "comment uid"
+// @Param 	pkg.UpdateCommentOpts body pkg.UpdateCommentOpts true "update comment opts"
+// @Success 200 {object} common.JSONResult{data=pkg.Comment} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /comments/{cid} [PUT]
+func UpdateComment(c *gin.Context) {
+	var (
+		comment *pkg.Comment
+		err     error
+	)
+	defer func() { common.Resp(c, comment, err) }()
+
+	opts := &pkg.UpdateCommentOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	comment, err = models.GetCommentById(opts.Cid)
+	if err != nil {
+		return
+	}
+	if comment.MemberID != common.GetUID(c) {
+		comment = nil
+		err = fmt.Errorf("you can't update other's comment")
+		return
+	}
+
+	if len(opts.Scores) != 0 {
+		var app *pkg.Application
+		if app, err = models.GetApplicationByIdForCandidate(comment.ApplicationID); err != nil {
+			comment = nil
+			return
+		}
+		if opts.Score, err = scoreComment(app, opts.Scores); err != nil {
+			comment = nil
+			return
+		}
+	}
+
+	comment, err = models.UpdateComment(opts.Cid, opts, common.GetUID(c))
+	return
+}
+
+// GetCommentRevisions get revisions of comment
+// @Id get_comment_revisions.
+// @Summary get the edit history of comment
+// @Description get the comment before each edit, the latest first. Only can be got by member who can see the comment.
+// @Tags comment
+// @Accept  json
+// @Produce  json
+// @Param 	cid path string true "comment uid"
+// @Success 200 {object} common.JSONResult{data=[]pkg.CommentRevision} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /comments/{cid}/revisions [GET]
+func GetCommentRevisions(c *gin.Context) {
+	var (
+		comment   *pkg.Comment
+		app       *pkg.Application
+		revisions []pkg.CommentRevision
+		err       error
+	)
+	defer func() { common.Resp(c, revisions, err) }()
+
+	cid := c.Param("cid")
+	if cid == "" {
+		err = fmt.Errorf("request param error, comment id is nil")
+		return
+	}
+	comment, err = models.GetCommentById(cid)
+	if err != nil {
+		return
+	}
+	app, err = models.GetApplicationById(comment.ApplicationID)
+	if err != nil {
+		return
+	}
+	if err = checkCommentVisible(c, app, cid); err != nil {
+		return
+	}
+
+	revisions, err = models.GetCommentRevisions(cid)
+	return
+}
+
+// checkCommentParent check the comment replied to is on the same application and can be seen by member,
+// the reply takes its visibility by default
+func checkCommentParent(c *gin.Context, opts *pkg.CreateCommentOpts, app *pkg.Application) error {
+	var parent *pkg.Comment
+	for i := range app.Comments {
+		if app.Comments[i].Uid == opts.ParentID {
+			parent = &app.Comments[i]
+			break
+		}
+	}
+	if parent == nil {
+		return fmt.Errorf("comment %s is not on this application", opts.ParentID)
+	}
+	if err := checkCommentVisible(c, app, opts.ParentID); err != nil {
+		return err
+	}
+	if opts.Visibility == "" {
+		opts.Visibility = parent.Visibility
+	}
+	return nil
+}
+
+// checkCommentVisible check the comment of application (with comments loaded) can be seen by member
+func checkCommentVisible(c *gin.Context, app *pkg.Application, cid string) error {
+	apps := []pkg.Application{*app}
+	if err := filterComments(c, apps); err != nil {
+		return err
+	}
+	for _, comment := range apps[0].Comments {
+		if comment.Uid == cid {
+			return nil
+		}
+	}
+	return fmt.Errorf("you can't see comment %s", cid)
+}
+
+// scoreComment check the scores against the rubric of application's group, and get the weighted score
+func scoreComment(app *pkg.Application, scores map[string]int) (*float64, error) {
+	rubric, err := models.GetRubric(app.RecruitmentID, app.Group)
+	if err != nil {
+		return nil, err
+	}
+	if rubric == nil {
+		return nil, fmt.Errorf("group %s doesn't have a rubric to score", app.Group)
+	}
+
+	score, err := rubric.Score(scores)
+	if err != nil {
+		return nil, err
+	}
+	return &score, nil
+}
+
+func getCommentViewer(c *gin.Context) (*pkg.CommentViewer, error) {
+	viewer := &pkg.CommentViewer{UID: common.GetUID(c), IsAdmin: common.IsAdmin(c)}
+	if viewer.IsAdmin {
+		return viewer, nil
+	}
+	member, err := grpc.GetUserInfoByUID(viewer.UID)
+	if err != nil {
+		return nil, err
+	}
+	viewer.Groups = member.Groups
+	return viewer, nil
+}
+
+// filterComments remove the comments of applications the member can't see by their visibility,
+// and the comments hidden by blind evaluation. Admins see all of them.
+// The average score is recomputed from the comments left.
+func filterComments(c *gin.Context, apps []pkg.Application) error {
+	viewer, err := getCommentViewer(c)
+	if err != nil {
+		return err
+	}
+	if viewer.IsAdmin {
+		return nil
+	}
+
+	blindSteps := make(map[string][]pkg.Step) // rid -> blind steps
+	aids := make([]string, 0, len(apps))
+	for _, app := range apps {
+		aids = append(aids, app.Uid)
+		if _, ok := blindSteps[app.RecruitmentID]; ok {
+			continue
+		}
+		r, err := models.GetRecruitmentById(app.RecruitmentID)
+		if err != nil {
+			return err
+		}
+		blindSteps[app.RecruitmentID] = r.BlindSteps
+	}
+	revealed, err := models.GetRevealedSteps(aids)
+	if err != nil {
+		return err
+	}
+
+	for i := range apps {
+		loaded := len(apps[i].Comments)
+		comments := apps[i].Comments[:0]
+		for _, comment := range apps[i].Comments {
+			if comment.VisibleTo(viewer.UID, viewer.Groups, viewer.IsAdmin, apps[i].Group) {
+				comments = append(comments, comment)
+			}
+		}
+		apps[i].Comments = pkg.FilterBlindComments(comments, viewer.UID, blindSteps[apps[i].RecruitmentID], revealed[apps[i].Uid])
+		// the aggregates of all comments would give away the hidden ones
+		if len(apps[i].Comments) < loaded {
+			apps[i].Score = pkg.AverageScore(apps[i].Comments)
+			apps[i].AverageEvaluation = nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+// GetEvaluationRounds get evaluation rounds of application.
+// @Id get_evaluation_rounds.
+// @Summary get the interviewers assigned to evaluate application blind
+// @Description get the interviewers assigned to evaluate application at each blind step, who of them have submitted and whether the comments are revealed
+// @Tags evaluation
+// @Accept  json
+// @Produce  json
+// @Param	aid path string true "application id"
+// @Success 200 {object} common.JSONResult{data=[]pkg.EvaluationRound} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/evaluations [get]
+func GetEvaluationRounds(c *gin.Context) {
+	var (
+		rounds []pkg.EvaluationRound
+		err    error
+	)
+	defer func() { common.Resp(c, rounds, err) }()
+
+	aid := c.Param("aid")
+	if aid == "" {
+		err = fmt.Errorf("request param error, application id is nil")
+		return
+	}
+
+	rounds, err = models.GetEvaluationRounds(aid)
+	return
+}
+
+// SetEvaluators assign interviewers to evaluate application.
+// @Id set_evaluators.
+// @Summary assign interviewers to evaluate application at a blind step
+// @Description assign the members who evaluate application at the step, comments of the step can be revealed after all of them have commented. Can only be set by member of the corresponding group before the comments are revealed
+// @Tags evaluation
+// @Accept  json
+// @Produce  json
+// @Param	aid path string true "application id"
+// @Param	step path pkg.Step true "step"
+// @Param 	pkg.SetEvaluatorsOpts body pkg.SetEvaluatorsOpts true "uid of members"
+// @Success 200 {object} common.JSONResult{data=pkg.EvaluationRound} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/evaluations/{step}/evaluators [put]
+func SetEvaluators(c *gin.Context) {
+	var (
+		app   *pkg.Application
+		round *pkg.EvaluationRound
+		err   error
+	)
+	defer func() { common.Resp(c, round, err) }()
+
+	opts := &pkg.SetEvaluatorsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+
+	if app, err = getBlindApplication(opts.Aid, opts.Step); err != nil {
+		return
+	}
+	if !common.IsAdmin(c) {
+		if err = checkMemberGroup(app.Uid, common.GetUID(c)); err != nil {
+			return
+		}
+	}
+
+	round, err = models.SetEvaluators(opts.Aid, opts.Step, opts.Evaluators)
+	return
+}
+
+// RevealComments reveal comments of application at a blind step.
+// @Id reveal_comments.
+// @Summary reveal comments of application at a blind step
+// @Description reveal the comments of application made at the step to all members who can see them, can only be revealed by admin after every assigned interviewer has commented. If interviewers are not assigned, the interviewers of the interview allocated to application are taken
+// @Tags evaluation
+// @Accept  json
+// @Produce  json
+// @Param	aid path string true "application id"
+// @Param	step path pkg.Step true "step"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/evaluations/{step}/revealed [put]
+func RevealComments(c *gin.Context) {
+	var err error
+	defer func() { common.Resp(c, nil, err) }()
+
+	opts := &pkg.RevealCommentsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+
+	if _, err = getBlindApplication(opts.Aid, opts.Step); err != nil {
+		return
+	}
+
+	err = models.RevealComments(opts.Aid, opts.Step, common.GetOperator(c))
+	return
+}
+
+// getBlindApplication get the application and check the step is evaluated blind in its recruitment
+func getBlindApplication(aid string, step pkg.Step) (*pkg.Application, error) {
+	app, err := models.GetApplicationByIdForCandidate(aid)
+	if err != nil {
+		return nil, err
+	}
+	r, err := models.GetRecruitmentById(app.RecruitmentID)
+	if err != nil {
+		return nil, err
+	}
+	for _, blind := range r.BlindSteps {
+		if blind == step {
+			return app, nil
+		}
+	}
+	return nil, fmt.Errorf("step %s is not evaluated blind", step)
+}
@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xylonx/zapx"
+	"go.uber.org/zap"
+
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/internal/utils"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/grpc"
+	"UniqueRecruitmentBackend/pkg/xlsx"
+)
+
+// ExportApplications export applications of recruitment as spreadsheet.
+// @Id export_applications.
+// @Summary export applications of recruitment as csv or xlsx
+// @Description export applications in member's groups (all groups for admin) with candidate info, step, interview allocations and evaluation summary. The file is streamed, so errors after the first row are only logged and the file is truncated
+// @Tags application
+// @Produce  text/csv
+// @Produce  application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param	rid path string true "recruitment id"
+// @Param	format query string false "csv or xlsx, csv by default"
+// @Param	group query pkg.Group false "only export this group"
+// @Success 200 {file} file "spreadsheet"
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/export [get]
+func ExportApplications(c *gin.Context) {
+	var (
+		r      *pkg.Recruitment
+		member *pkg.UserDetail
+		w      rowWriter
+		err    error
+	)
+	defer func() {
+		if err != nil {
+			common.Resp(c, nil, err)
+		}
+	}()
+
+	opts := &pkg.ExportApplicationsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindQuery(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	var groups []string
+	viewer := &pkg.CommentViewer{UID: common.GetUID(c), IsAdmin: common.IsAdmin(c)}
+	if viewer.IsAdmin {
+		for group := range pkg.GroupMap {
+			groups = append(groups, string(group))
+		}
+	} else {
+		member, err = grpc.GetUserInfoByUID(viewer.UID)
+		if err != nil {
+			return
+		}
+		groups = member.Groups
+		viewer.Groups = member.Groups
+	}
+	if opts.Group != "" {
+		if !utils.CheckInGroups(groups, opts.Group) {
+			err = fmt.Errorf("you are not in group %s", opts.Group)
+			return
+		}
+		groups = []string{string(opts.Group)}
+	}
+
+	r, err = models.GetRecruitmentById(opts.Rid)
+	if err != nil {
+		return
+	}
+	recruitmentName := utils.ConvertRecruitmentName(r.Name)
+
+	filename := recruitmentName
+	if opts.Group != "" {
+		filename = fmt.Sprintf("%s-%s", recruitmentName, opts.Group)
+	}
+	filename = fmt.Sprintf("%s.%s", filename, opts.Format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q; filename*=UTF-8''%s", "applications."+opts.Format, url.PathEscape(filename)))
+
+	// the response is started from here, errors can't be sent to client any more
+	c.Status(http.StatusOK)
+	var errExport error
+	if opts.Format == pkg.ExportXLSX {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		w, errExport = xlsx.NewWriter(c.Writer, recruitmentName)
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w, errExport = newCSVRowWriter(c.Writer)
+	}
+	if errExport == nil {
+		errExport = exportApplications(c, w, opts.Rid, groups, viewer)
+	}
+	if errExport == nil {
+		errExport = w.Close()
+	}
+	if errExport != nil {
+		zapx.Error("export applications failed", zap.String("rid", opts.Rid), zap.Error(errExport))
+		c.Abort()
+	}
+}
+
+func exportApplications(c *gin.Context, w rowWriter, rid string, groups []string, viewer *pkg.CommentViewer) error {
+	if err := w.WriteRow(exportHeader()); err != nil {
+		return err
+	}
+	return models.ExportApplications(rid, groups, viewer, func(apps []pkg.Application, counts models.EvaluationCount) error {
+		utils.FillApplicationsUserDetail(c, apps)
+		for i := range apps {
+			if err := w.WriteRow(exportRow(&apps[i], counts[apps[i].Uid])); err != nil {
+				return err
+			}
+		}
+		// send each batch to client so that large exports aren't buffered
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+}
+
+var exportEvaluations = []pkg.Evaluation{pkg.Good, pkg.Normal, pkg.Bad}
+
+func exportHeader() []string {
+	header := []string{
+		"姓名", "性别", "手机", "邮箱", "组别", "年级", "学院", "专业", "排名", "速通", "推荐人",
+		"当前阶段", "状态",
+		pkg.EnToZhStepMap[pkg.GroupInterview] + "时间",
+		pkg.EnToZhStepMap[pkg.TeamInterview] + "时间",
+	}
+	for _, evaluation := range exportEvaluations {
+		header = append(header, pkg.EnToZhEvaluationMap[evaluation]+"评")
+	}
+	return append(header, "平均评价", "简历", "报名时间")
+}
+
+func exportRow(app *pkg.Application, counts map[pkg.Evaluation]int) []string {
+	user := app.UserDetail
+	if user == nil {
+		user = &pkg.UserDetail{}
+	}
+
+	status := "进行中"
+	switch {
+	case app.Abandoned:
+		status = "已放弃"
+	case app.Rejected:
+		status = "已淘汰"
+	case app.Step == pkg.Pass:
+		status = "已通过"
+	}
+
+	isQuick := "否"
+	if app.IsQuick {
+		isQuick = "是"
+	}
+
+	var groupInterview, teamInterview string
+	if app.InterviewAllocationsGroupId != "" {
+		groupInterview = utils.FormatLocalTime(app.InterviewAllocationsGroup.Start)
+	}
+	if app.InterviewAllocationsTeamId != "" {
+		teamInterview = utils.FormatLocalTime(app.InterviewAllocationsTeam.Start)
+	}
+
+	row := []string{
+		user.Name, pkg.EnToZhGenderMap[user.Gender], user.Phone, user.Email,
+		string(app.Group), app.Grade, app.Institute, app.Major, app.Rank, isQuick, app.Referrer,
+		pkg.EnToZhStepMap[app.Step], status,
+		groupInterview, teamInterview,
+	}
+	for _, evaluation := range exportEvaluations {
+		row = append(row, strconv.Itoa(counts[evaluation]))
+	}
+
+	var average string
+	if app.AverageEvaluation != nil {
+		average = strconv.FormatFloat(*app.AverageEvaluation, 'f', 2, 64)
+	}
+	var resume string
+	if app.Resume != "" {
+		resume = "有"
+	}
+	return append(row, average, resume, utils.FormatLocalTime(app.CreatedAt))
+}
+
+// rowWriter writes rows of spreadsheet, Flush sends the written rows to the underlying writer
+type rowWriter interface {
+	WriteRow(cells []string) error
+	Flush() error
+	Close() error
+}
+
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+// newCSVRowWriter writes utf-8 bom first, otherwise excel takes the file as gbk
+func newCSVRowWriter(w http.ResponseWriter) (*csvRowWriter, error) {
+	if _, err := w.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	return &csvRowWriter{w: csv.NewWriter(w)}, nil
+}
+
+// WriteRow quotes the cells which excel would take as formulas, the cells of xlsx are inline strings so they are safe
+func (w *csvRowWriter) WriteRow(cells []string) error {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = escapeCSVFormula(cell)
+	}
+	return w.w.Write(escaped)
+}
+
+// escapeCSVFormula prefixes the cell starting with a formula character with ', such as the intro filled by candidate
+func escapeCSVFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (w *csvRowWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvRowWriter) Close() error {
+	return w.Flush()
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/pkg/storage"
+)
+
+// ServeLocalFile download file by the url presigned by local storage.
+// @Id serve_local_file.
+// @Summary download file by presigned url of local storage
+// @Description download file by the url got from resume url etc. when the local storage driver is used, the url is signed with expires and signature so no session is required
+// @Tags file
+// @Produce  octet-stream
+// @Param	key path string true "file key"
+// @Param	expires query int true "unix time the url expires at"
+// @Param	signature query string true "signature of url"
+// @Success 200 {file} file ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /files/{key} [get]
+func ServeLocalFile(c *gin.Context) {
+	var (
+		obj *storage.Object
+		err error
+	)
+	defer func() {
+		if err != nil {
+			common.Resp(c, nil, err)
+		}
+	}()
+
+	local, ok := global.GetStorage().(*storage.LocalStorage)
+	if !ok {
+		err = errors.New("local storage is not enabled")
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err = local.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+		return
+	}
+	obj, err = local.Get(c.Request.Context(), key)
+	if err != nil {
+		return
+	}
+	defer obj.Body.Close()
+
+	c.DataFromReader(http.StatusOK, obj.ContentLength, obj.ContentType, obj.Body, nil)
+}
+
+// UploadLocalFile upload file by the url presigned by local storage.
+// @Id upload_local_file.
+// @Summary upload file by presigned url of local storage
+// @Description upload file by the url got from create_upload when the local storage driver is used, Content-Type and Content-Length must be the ones the url is signed with
+// @Tags file
+// @Accept  octet-stream
+// @Produce  json
+// @Param	key path string true "file key"
+// @Param	expires query int true "unix time the url expires at"
+// @Param	signature query string true "signature of url"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /files/{key} [put]
+func UploadLocalFile(c *gin.Context) {
+	var err error
+	defer func() { common.Resp(c, nil, err) }()
+
+	local, ok := global.GetStorage().(*storage.LocalStorage)
+	if !ok {
+		err = errors.New("local storage is not enabled")
+		return
+	}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	contentType := c.GetHeader("Content-Type")
+	size := c.Request.ContentLength
+	if err = local.VerifyPut(key, c.Query("expires"), c.Query("signature"), contentType, size); err != nil {
+		return
+	}
+	err = local.Put(c.Request.Context(), key, http.MaxBytesReader(c.Writer, c.Request.Body, size), size, contentType)
+}
@@ -18,7 +18,7 @@ import (
 // GetRecruitmentInterviews get recruitment interviews
 // @Id get_recruitment_interviews.
 // @Summary get recruitment interviews.
-// @Description get recruitment interviews, candidate can't see slotNumber and selectNumber of Interviews (will get interviews of groups or unique), guarantee slotNumber > selectNumber
+// @Description get recruitment interviews (will get interviews of groups or unique), remaining is the number of seats left (omitted if the interview is unlimited)
 // @Tags interviews
 // @Accept  json
 // @Produce  json
@@ -221,22 +221,26 @@ func SetRecruitmentInterviews(c *gin.Context) {
 				Common: pkg.Common{
 					Uid: interview.Uid,
 				},
-				Name:          name,
-				RecruitmentID: rid,
-				Date:          interview.Date,
-				Period:        interview.Period,
-				Start:         interview.Start,
-				End:           interview.End,
+				Name:              name,
+				RecruitmentID:     rid,
+				Date:              interview.Date,
+				Period:            interview.Period,
+				Start:             interview.Start,
+				End:               interview.End,
+				SlotNumber:        interview.SlotNumber,
+				InterviewerNumber: interview.InterviewerNumber,
 			}
 		} else {
 			// add
 			interviewsToAdd = append(interviewsToAdd, pkg.Interview{
-				Name:          name,
-				RecruitmentID: rid,
-				Date:          interview.Date,
-				Period:        interview.Period,
-				Start:         interview.Start,
-				End:           interview.End,
+				Name:              name,
+				RecruitmentID:     rid,
+				Date:              interview.Date,
+				Period:            interview.Period,
+				Start:             interview.Start,
+				End:               interview.End,
+				SlotNumber:        interview.SlotNumber,
+				InterviewerNumber: interview.InterviewerNumber,
 			})
 		}
 	}
@@ -290,6 +294,67 @@ func SetRecruitmentInterviews(c *gin.Context) {
 	return
 }
 
+// AllocateRecruitmentInterviews allocate recruitment interviews
+// @Id allocate_recruitment_interviews.
+// @Summary allocate group/team interviews for candidates automatically.
+// @Description allocate each candidate in GroupTimeSelection/TeamTimeSelection step an interview he/she selected, an interview holds at most slot_number candidates (capacity for interviews without slot number). Candidates already allocated are kept unless reallocate is set.
+// @Tags interviews
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param 	name path pkg.Group true "pkg.Group or unique"
+// @Param	pkg.AllocateInterviewsOpts body pkg.AllocateInterviewsOpts true "allocate interviews opts"
+// @Success 200 {object} common.JSONResult{data=pkg.AllocateInterviewsResp} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/interviews/{name}/allocate [post]
+func AllocateRecruitmentInterviews(c *gin.Context) {
+	var (
+		resp *pkg.AllocateInterviewsResp
+		r    *pkg.Recruitment
+		user *pkg.UserDetail
+		err  error
+	)
+
+	defer func() { common.Resp(c, resp, err) }()
+
+	opts := &pkg.AllocateInterviewsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	r, err = models.GetRecruitmentById(opts.Rid)
+	if err != nil {
+		return
+	}
+	if err = checkRecruitmentTimeInBtoE(r); err != nil {
+		return
+	}
+
+	user, err = grpc.GetUserInfoByUID(common.GetUID(c))
+	if err != nil {
+		return
+	}
+
+	// member can only allocate his group's interview or team interview (组面/群面
+	if err = checkInterviewGroupName(user, opts.Name); err != nil {
+		return
+	}
+
+	resp, err = models.AllocateInterviews(opts, common.GetOperator(c))
+	if err != nil {
+		return
+	}
+	zapx.Infof("allocate %s interviews of recruitment %s: %d allocated, %d unallocated",
+		opts.Name, opts.Rid, len(resp.Allocations), len(resp.Unallocated))
+	return
+}
+
 // check user's group == name
 func checkInterviewGroupName(user *pkg.UserDetail, name pkg.Group) error {
 	if name != pkg.Unique {
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/internal/utils"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/grpc"
+)
+
+// SignUpInterviewer sign up as interviewer of interview.
+// @Id sign_up_interviewer.
+// @Summary sign up as interviewer of interview
+// @Description member signs up to run the interview of their group (or unique), it fails if the interview already has enough interviewers
+// @Tags interviewer
+// @Accept  json
+// @Produce  json
+// @Param	iid path string true "interview id"
+// @Success 200 {object} common.JSONResult{data=pkg.Interviewer} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /interviews/{iid}/interviewers [put]
+func SignUpInterviewer(c *gin.Context) {
+	var (
+		interview   *pkg.Interview
+		member      *pkg.UserDetail
+		interviewer *pkg.Interviewer
+		err         error
+	)
+	defer func() { common.Resp(c, interviewer, err) }()
+
+	iid := c.Param("iid")
+	if iid == "" {
+		err = errors.New("request param error, interview id is nil")
+		return
+	}
+
+	interview, err = models.GetInterviewById(iid)
+	if err != nil {
+		return
+	}
+	if interview.End.Before(time.Now()) {
+		err = errors.New("the interview has already ended")
+		return
+	}
+
+	member, err = grpc.GetUserInfoByUID(common.GetUID(c))
+	if err != nil {
+		return
+	}
+	if interview.Name != pkg.Unique && !utils.CheckInGroups(member.Groups, interview.Name) {
+		err = fmt.Errorf("you can't run interviews of group %s", interview.Name)
+		return
+	}
+
+	interviewer, err = models.AddInterviewer(iid, member)
+	return
+}
+
+// WithdrawInterviewer withdraw from interviewers of interview.
+// @Id withdraw_interviewer.
+// @Summary withdraw from interviewers of interview
+// @Description member withdraws from the interview they signed up for
+// @Tags interviewer
+// @Accept  json
+// @Produce  json
+// @Param	iid path string true "interview id"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /interviews/{iid}/interviewers [delete]
+func WithdrawInterviewer(c *gin.Context) {
+	var err error
+	defer func() { common.Resp(c, nil, err) }()
+
+	iid := c.Param("iid")
+	if iid == "" {
+		err = errors.New("request param error, interview id is nil")
+		return
+	}
+
+	err = models.RemoveInterviewer(iid, common.GetUID(c))
+	return
+}
+
+// GetInterviewPanels get interviewers of recruitment interviews.
+// @Id get_interview_panels.
+// @Summary get the interviewers of recruitment interviews
+// @Description get the interviews of group (or unique) with their interviewers and the number of allocated candidates, unstaffed is true if candidates are allocated but nobody interviews them, understaffed is true if they have fewer interviewers than interviewer_number
+// @Tags interviewer
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param 	name path pkg.Group true "pkg.Group"
+// @Success 200 {object} common.JSONResult{data=[]pkg.Interview} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/interviews/{name}/panels [get]
+func GetInterviewPanels(c *gin.Context) {
+	var (
+		interviews []pkg.Interview
+		err        error
+	)
+	defer func() { common.Resp(c, interviews, err) }()
+
+	opts := &pkg.GetInterviewsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	interviews, err = models.GetInterviewPanels(opts.Rid, opts.Name)
+	return
+}
+
+// GetMyInterviewsToday get interviews member runs today.
+// @Id get_my_interviews_today.
+// @Summary get the interviews member runs today
+// @Description get the interviews member signed up for which start today (Asia/Shanghai), with the candidates allocated to them. Abandoned and rejected candidates are not included, comments are the ones visible to member
+// @Tags interviewer
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} common.JSONResult{data=[]pkg.MyInterview} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /interviews/today [get]
+func GetMyInterviewsToday(c *gin.Context) {
+	var (
+		interviews []pkg.MyInterview
+		err        error
+	)
+	defer func() { common.Resp(c, interviews, err) }()
+
+	start, end := utils.LocalDay(time.Now())
+	interviews, err = models.GetMemberInterviews(common.GetUID(c), start, end)
+	if err != nil {
+		return
+	}
+
+	for i := range interviews {
+		if err = filterComments(c, interviews[i].Candidates); err != nil {
+			return
+		}
+		utils.FillApplicationsUserDetail(c, interviews[i].Candidates)
+	}
+	return
+}
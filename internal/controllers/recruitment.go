@@ -15,6 +15,8 @@ import (
 	"UniqueRecruitmentBackend/internal/utils"
 	"UniqueRecruitmentBackend/pkg"
 	"UniqueRecruitmentBackend/pkg/grpc"
+	"UniqueRecruitmentBackend/pkg/storage"
+	"UniqueRecruitmentBackend/pkg/upload"
 )
 
 // CreateRecruitment create recruitment
@@ -133,6 +135,10 @@ func GetRecruitmentById(c *gin.Context) {
 			if err != nil {
 				return
 			}
+			utils.FillApplicationsUserDetail(c, r.Applications)
+			if err = filterComments(c, r.Applications); err != nil {
+				return
+			}
 			r.GroupDetails, err = grpc.GetGroupsDetail()
 			if err != nil {
 				return
@@ -199,6 +205,10 @@ func GetPendingRecruitment(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		utils.FillApplicationsUserDetail(c, r.Applications)
+		if err = filterComments(c, r.Applications); err != nil {
+			return
+		}
 		r.GroupDetails, err = grpc.GetGroupsDetail()
 		if err != nil {
 			return
@@ -239,6 +249,83 @@ func SetStressTestTime(c *gin.Context) {
 	return
 }
 
+// SetRecruitmentSteps set the steps of recruitment
+// @Id set_recruitment_steps
+// @Summary set the steps applications of the recruitment go through.
+// @Description set the ordered steps of recruitment, steps should start with SignUp and end with Pass, only can be set by admin. Steps which applications stay at can't be removed.
+// @Tags recruitment
+// @Accept  json
+// @Produce  json
+// @Param 	rid path string true "recruitment uid"
+// @Param 	pkg.SetRecStepsOpts body pkg.SetRecStepsOpts true "set recruitment steps opts"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/steps [put]
+func SetRecruitmentSteps(c *gin.Context) {
+	var (
+		err error
+	)
+	defer func() { common.Resp(c, nil, err) }()
+
+	opts := &pkg.SetRecStepsOpts{}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	opts.Rid = c.Param("rid")
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	err = models.UpdateRecruitmentSteps(opts)
+	return
+}
+
+// SetRecruitmentBlindSteps set the steps of recruitment evaluated blind
+// @Id set_recruitment_blind_steps.
+// @Summary set the steps of recruitment evaluated blind.
+// @Description set the steps at which members can't see others' comments on an application until they have commented, all steps if all is true, empty steps to turn it off. Can only be set by admin
+// @Tags recruitment
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param 	pkg.SetRecBlindStepsOpts body pkg.SetRecBlindStepsOpts true "blind steps"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/blind [put]
+func SetRecruitmentBlindSteps(c *gin.Context) {
+	var (
+		r   *pkg.Recruitment
+		err error
+	)
+	defer func() { common.Resp(c, nil, err) }()
+
+	opts := &pkg.SetRecBlindStepsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+
+	r, err = models.GetRecruitmentById(opts.Rid)
+	if err != nil {
+		return
+	}
+	pipeline := r.GetPipeline()
+	if opts.All {
+		opts.Steps = pipeline
+	}
+	for _, step := range opts.Steps {
+		if !pipeline.Contains(step) {
+			err = fmt.Errorf("request body error, step %s is not in the recruitment", step)
+			return
+		}
+	}
+
+	err = models.UpdateRecruitmentBlindSteps(opts.Rid, opts.Steps)
+	return
+}
+
 // UploadRecruitmentFile upload recruitment file
 // @Id upload_recruitment_file
 // @Summary upload recruitment file, such as written test.
@@ -272,9 +359,14 @@ func UploadRecruitmentFile(c *gin.Context) {
 		return
 	}
 
+	// only pdf is allowed, so the key is always .pdf
+	if _, err = validateFile(c, upload.PurposeRecruitmentFile, opts.File); err != nil {
+		return
+	}
+
 	// file path example: 2023秋(rname)/web(group)/WrittenTest.pdf(type)
 	filePath := fmt.Sprintf("%s/%s/%s", r.Name, opts.Group, fmt.Sprintf("%s.pdf", opts.Type))
-	err = global.UpLoadAndSaveFileToCos(opts.File, filePath)
+	err = storage.PutFile(c.Request.Context(), global.GetStorage(), filePath, opts.File)
 	return
 }
 
@@ -312,17 +404,48 @@ func DownloadRecruitmentFile(c *gin.Context) {
 
 	// file path example: 2023秋(rname)/web(group)/WrittenTest.pdf(type)
 	filePath := fmt.Sprintf("%s/%s/%s", r.Name, opts.Group, fmt.Sprintf("%s.pdf", opts.Type))
-	resp, err := global.GetCOSObjectResp(filePath)
+	obj, err := global.GetStorage().Get(c.Request.Context(), filePath)
 	if err != nil {
 		common.Resp(c, nil, err)
 		return
 	}
+	defer obj.Body.Close()
+
+	c.DataFromReader(http.StatusOK, obj.ContentLength, obj.ContentType, obj.Body, nil)
+}
 
-	reader := resp.Body
-	contentLength := resp.ContentLength
-	contentType := resp.Header.Get("Content-Type")
+// GetRecruitmentAnalytics get analytics of recruitment
+// @Id get_recruitment_analytics.
+// @Summary get the funnel, conversion and time-in-step report of recruitment.
+// @Description get the funnel of the recruitment (in total and per group) with conversion rates, median time in each stage and drop-off, the breakdown by grade and institute, and the summary of previous recruitments to compare. Stages are the steps sharing a rank in StepRanks
+// @Tags recruitment
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param	compare query int false "number of previous recruitments to compare, 3 by default"
+// @Success 200 {object} common.JSONResult{data=pkg.RecruitmentAnalytics} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/analytics [get]
+func GetRecruitmentAnalytics(c *gin.Context) {
+	var (
+		analytics *pkg.RecruitmentAnalytics
+		err       error
+	)
+	defer func() { common.Resp(c, analytics, err) }()
 
-	c.DataFromReader(http.StatusOK, contentLength, contentType, reader, nil)
+	opts := &pkg.GetRecAnalyticsOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindQuery(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	analytics, err = models.GetRecruitmentAnalytics(opts.Rid, *opts.Compare)
+	return
 }
 
 func checkJoinTime(joinTime string, recruitmentTime time.Time) bool {
@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xylonx/zapx"
+	"go.uber.org/zap"
+
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/jobs"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/internal/utils"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/email"
+	"UniqueRecruitmentBackend/pkg/grpc"
+)
+
+// CreateRescheduleRequest candidate request to reschedule the allocated interview.
+// @Id create_reschedule_request.
+// @Summary candidate request to reschedule the allocated interview
+// @Description candidate who can't attend the allocated group/team interview files a request with a reason and alternative interviews (got from slots), an application can only have one pending request for each type
+// @Tags reschedule
+// @Accept  json
+// @Produce  json
+// @Param	aid path string true "application id"
+// @Param	type path pkg.GroupOrTeam true "group or team"
+// @Param pkg.CreateRescheduleOpts body pkg.CreateRescheduleOpts true "reason and alternative interviews"
+// @Success 200 {object} common.JSONResult{data=pkg.RescheduleRequest} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/reschedule/{type} [post]
+func CreateRescheduleRequest(c *gin.Context) {
+	var (
+		app *pkg.Application
+		r   *pkg.Recruitment
+		req *pkg.RescheduleRequest
+		err error
+	)
+	defer func() { common.Resp(c, req, err) }()
+
+	opts := &pkg.CreateRescheduleOpts{}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	opts.Aid = c.Param("aid")
+	opts.InterviewType = pkg.GroupOrTeam(c.Param("type"))
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	app, err = models.GetApplicationByIdForCandidate(opts.Aid)
+	if err != nil {
+		return
+	}
+
+	// check if user is the application's owner
+	if app.CandidateID != common.GetUID(c) {
+		err = errors.New("you can't reschedule other's interview")
+		return
+	}
+	if err = checkApplyStatus(app); err != nil {
+		return
+	}
+
+	r, err = models.GetRecruitmentById(app.RecruitmentID)
+	if err != nil {
+		return
+	}
+	if err = checkRecruitmentTimeInBtoE(r); err != nil {
+		return
+	}
+
+	var iid string
+	var name pkg.Group
+	if opts.InterviewType == pkg.InGroup {
+		iid, name = app.InterviewAllocationsGroupId, app.Group
+	} else {
+		iid, name = app.InterviewAllocationsTeamId, pkg.Unique
+	}
+	if iid == "" {
+		err = fmt.Errorf("%s interview time is not allocated", opts.InterviewType)
+		return
+	}
+
+	var alternatives []pkg.Interview
+	alternatives, err = models.GetInterviewsByIdsAndName(opts.Iids, name)
+	if err != nil {
+		return
+	}
+	if err = checkRescheduleAlternatives(alternatives, opts.Iids, app.RecruitmentID, iid, opts.InterviewType); err != nil {
+		return
+	}
+
+	req = &pkg.RescheduleRequest{
+		ApplicationID: app.Uid,
+		InterviewType: opts.InterviewType,
+		InterviewID:   iid,
+		Reason:        opts.Reason,
+		Alternatives:  alternatives,
+	}
+	err = models.CreateRescheduleRequest(req, common.GetOperator(c))
+	return
+}
+
+// GetRecruitmentReschedules get reschedule requests of recruitment.
+// @Id get_recruitment_reschedules.
+// @Summary get reschedule requests of the groups member is in
+// @Description get reschedule requests of applications in member's groups, pending requests by default
+// @Tags reschedule
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param	status query pkg.RescheduleStatus false "pending, approved or denied"
+// @Success 200 {object} common.JSONResult{data=[]pkg.RescheduleRequest} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/reschedules [get]
+func GetRecruitmentReschedules(c *gin.Context) {
+	var (
+		member *pkg.UserDetail
+		reqs   []pkg.RescheduleRequest
+		err    error
+	)
+	defer func() { common.Resp(c, reqs, err) }()
+
+	opts := &pkg.GetReschedulesOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindQuery(opts); err != nil {
+		return
+	}
+	if opts.Status == "" {
+		opts.Status = pkg.ReschedulePending
+	}
+
+	member, err = grpc.GetUserInfoByUID(common.GetUID(c))
+	if err != nil {
+		return
+	}
+
+	reqs, err = models.GetRescheduleRequestsByRid(opts.Rid, member.Groups, opts.Status)
+	return
+}
+
+// ApproveRescheduleRequest approve reschedule request.
+// @Id approve_reschedule_request.
+// @Summary approve reschedule request and move the candidate to one of the alternatives
+// @Description approve reschedule request, the application is set to the chosen interview like set_application_interview_time, can only be approved by member of the corresponding group. The candidate is notified by email, or by sms if email is not available
+// @Tags reschedule
+// @Accept  json
+// @Produce  json
+// @Param	id path string true "reschedule request id"
+// @Param pkg.ReviewRescheduleOpts body pkg.ReviewRescheduleOpts true "chosen interview and note"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /reschedules/{id}/approved [put]
+func ApproveRescheduleRequest(c *gin.Context) {
+	var (
+		req *pkg.RescheduleRequest
+		app *pkg.Application
+		err error
+	)
+	defer func() { common.Resp(c, nil, err) }()
+
+	opts := &pkg.ReviewRescheduleOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if opts.InterviewId == "" {
+		err = errors.New("request body error, interview id is nil")
+		return
+	}
+
+	if req, app, err = getRescheduleToReview(c, opts.ID); err != nil {
+		return
+	}
+
+	var chosen *pkg.Interview
+	for i := range req.Alternatives {
+		if req.Alternatives[i].Uid == opts.InterviewId {
+			chosen = &req.Alternatives[i]
+			break
+		}
+	}
+	if chosen == nil {
+		err = fmt.Errorf("interview %s is not one of the alternatives", opts.InterviewId)
+		return
+	}
+
+	if err = models.ApproveRescheduleRequest(req, app, chosen.Uid, opts.Note, common.GetOperator(c)); err != nil {
+		return
+	}
+
+	formatTime, errFormat := utils.ConverToLocationTime(chosen.Start)
+	if errFormat != nil {
+		zapx.Warn("format interview time failed", zap.Error(errFormat))
+		return
+	}
+	notifyReschedule(c, app, req, pkg.RescheduleApproval, rescheduleInterviewName(req.InterviewType), formatTime)
+	return
+}
+
+// DenyRescheduleRequest deny reschedule request.
+// @Id deny_reschedule_request.
+// @Summary deny reschedule request
+// @Description deny reschedule request, the allocated interview is kept, can only be denied by member of the corresponding group. The candidate is notified by email, or by sms if email is not available
+// @Tags reschedule
+// @Accept  json
+// @Produce  json
+// @Param	id path string true "reschedule request id"
+// @Param pkg.ReviewRescheduleOpts body pkg.ReviewRescheduleOpts true "note"
+// @Success 200 {object} common.JSONResult{} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /reschedules/{id}/denied [put]
+func DenyRescheduleRequest(c *gin.Context) {
+	var (
+		req *pkg.RescheduleRequest
+		app *pkg.Application
+		err error
+	)
+	defer func() { common.Resp(c, nil, err) }()
+
+	opts := &pkg.ReviewRescheduleOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	// note is optional
+	if errBind := c.ShouldBindJSON(opts); errBind != nil && !errors.Is(errBind, io.EOF) {
+		err = errBind
+		return
+	}
+
+	if req, app, err = getRescheduleToReview(c, opts.ID); err != nil {
+		return
+	}
+
+	if err = models.DenyRescheduleRequest(req, app, opts.Note, common.GetOperator(c)); err != nil {
+		return
+	}
+
+	note := opts.Note
+	if note == "" {
+		note = "请按原定时间参加面试"
+	}
+	notifyReschedule(c, app, req, pkg.RescheduleDenial, rescheduleInterviewName(req.InterviewType), note)
+	return
+}
+
+// getRescheduleToReview get the request and its application, and check member can review it
+func getRescheduleToReview(c *gin.Context, id string) (*pkg.RescheduleRequest, *pkg.Application, error) {
+	req, err := models.GetRescheduleRequestById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.Status != pkg.ReschedulePending {
+		return nil, nil, models.ErrRescheduleReviewed
+	}
+
+	app, err := models.GetApplicationByIdForCandidate(req.ApplicationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkApplyStatus(app); err != nil {
+		return nil, nil, err
+	}
+	if err = checkMemberGroup(app.Uid, common.GetUID(c)); err != nil {
+		return nil, nil, err
+	}
+
+	r, err := models.GetRecruitmentById(app.RecruitmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkRecruitmentTimeInBtoE(r); err != nil {
+		return nil, nil, err
+	}
+	return req, app, nil
+}
+
+// checkRescheduleAlternatives check alternatives are the future interviews with seats left
+// in the same recruitment, and the allocated interview is not one of them.
+// The seats are taken by the candidates allocated to the interview, not the selections
+func checkRescheduleAlternatives(alternatives []pkg.Interview, iids []string, rid string, allocated string, interviewType pkg.GroupOrTeam) error {
+	if len(alternatives) != len(iids) {
+		return errors.New("some of the interviews don't exist")
+	}
+	counts, err := models.CountInterviewAllocations(iids, interviewType, nil)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i := range alternatives {
+		interview := &alternatives[i]
+		if interview.RecruitmentID != rid {
+			return fmt.Errorf("interview %s is not in this recruitment", interview.Uid)
+		}
+		if interview.Uid == allocated {
+			return fmt.Errorf("interview %s is already allocated to you", interview.Uid)
+		}
+		if interview.Start.Before(now) {
+			return fmt.Errorf("interview %s has already started", interview.Uid)
+		}
+		if interview.SlotNumber > 0 && counts[interview.Uid] >= interview.SlotNumber {
+			return fmt.Errorf("interview %s is full", interview.Uid)
+		}
+	}
+	return nil
+}
+
+func rescheduleInterviewName(interviewType pkg.GroupOrTeam) string {
+	if interviewType == pkg.InGroup {
+		return pkg.EnToZhStepMap[pkg.GroupInterview]
+	}
+	return pkg.EnToZhStepMap[pkg.TeamInterview]
+}
+
+// notifyReschedule email the result of reschedule request to candidate, or send sms if email is not configured
+// or the candidate has no email. params follow the name of candidate.
+// The request has been reviewed, so failure is only logged
+func notifyReschedule(c *gin.Context, app *pkg.Application, req *pkg.RescheduleRequest, template pkg.SMSTemplateType, params ...string) {
+	candidate, err := grpc.GetUserInfoByUID(app.CandidateID)
+	if err != nil {
+		zapx.Warn("get candidate failed, candidate is not notified of reschedule", zap.String("uid", req.Uid), zap.Error(err))
+		return
+	}
+
+	msg := &pkg.SMSMessage{
+		ApplicationID: app.Uid,
+		OperatorID:    common.GetUID(c),
+		Template:      template,
+		Params:        append([]string{candidate.Name}, params...),
+	}
+	templateID, hasSMS := pkg.SMSTemplateMap[template]
+	switch {
+	case email.Enabled() && candidate.Email != "":
+		msg.Channel = pkg.ChannelEmail
+		msg.Email = candidate.Email
+		msg.Subject = pkg.EmailSubject
+	case hasSMS && candidate.Phone != "":
+		msg.Channel = pkg.ChannelSMS
+		msg.Phone = candidate.Phone
+		msg.TemplateID = templateID
+	default:
+		zapx.Warn("neither email nor sms is available, candidate is not notified of reschedule", zap.String("uid", req.Uid))
+		return
+	}
+	if err = models.CreateSMSMessages([]*pkg.SMSMessage{msg}); err != nil {
+		zapx.Error("save reschedule notification failed", zap.String("uid", req.Uid), zap.Error(err))
+		return
+	}
+	jobs.NotifySMS()
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/internal/utils"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/grpc"
+)
+
+// SetRubric set the scoring rubric of group.
+// @Id set_rubric.
+// @Summary set the scoring rubric of group in recruitment
+// @Description set the criteria (such as algorithm 1-5) and their weights the group scores applications by, can only be set by member of the group or admin. The rubric can't be changed once any application of the group is scored
+// @Tags rubric
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Param	group path pkg.Group true "group"
+// @Param pkg.SetRubricOpts body pkg.SetRubricOpts true "criteria"
+// @Success 200 {object} common.JSONResult{data=pkg.Rubric} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/rubrics/{group} [put]
+func SetRubric(c *gin.Context) {
+	var (
+		member *pkg.UserDetail
+		rubric *pkg.Rubric
+		err    error
+	)
+	defer func() { common.Resp(c, rubric, err) }()
+
+	opts := &pkg.SetRubricOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	if !common.IsAdmin(c) {
+		member, err = grpc.GetUserInfoByUID(common.GetUID(c))
+		if err != nil {
+			return
+		}
+		if !utils.CheckInGroups(member.Groups, opts.Group) {
+			err = fmt.Errorf("you are not in group %s", opts.Group)
+			return
+		}
+	}
+
+	if _, err = models.GetRecruitmentById(opts.Rid); err != nil {
+		return
+	}
+	rubric, err = models.SetRubric(opts)
+	return
+}
+
+// GetRubrics get the scoring rubrics of recruitment.
+// @Id get_rubrics.
+// @Summary get the scoring rubrics of all groups in recruitment
+// @Description get the scoring rubrics of all groups in recruitment, groups without rubric are not included
+// @Tags rubric
+// @Accept  json
+// @Produce  json
+// @Param	rid path string true "recruitment id"
+// @Success 200 {object} common.JSONResult{data=[]pkg.Rubric} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /recruitments/{rid}/rubrics [get]
+func GetRubrics(c *gin.Context) {
+	var (
+		rubrics []pkg.Rubric
+		err     error
+	)
+	defer func() { common.Resp(c, rubrics, err) }()
+
+	opts := &pkg.GetRecOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+
+	rubrics, err = models.GetRubricsByRid(opts.Rid)
+	return
+}
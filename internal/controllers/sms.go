@@ -6,12 +6,13 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/xylonx/zapx"
 
 	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/jobs"
 	"UniqueRecruitmentBackend/internal/models"
 	"UniqueRecruitmentBackend/internal/utils"
 	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/email"
 	"UniqueRecruitmentBackend/pkg/grpc"
 	"UniqueRecruitmentBackend/pkg/sms"
 )
@@ -19,7 +20,7 @@ import (
 // SendSMS send sms to user.
 // @Id send_sms
 // @Summary Send sms
-// @Description Send sms to user, include Accept, Reject, detailed information reference https://uniquestudio.feishu.cn/docx/Yh96d2DoyoCe6zxlR0ecSU5snDd?from=from_copylink
+// @Description Send sms and/or email to user by channels, include Accept, Reject, detailed information reference https://uniquestudio.feishu.cn/docx/Yh96d2DoyoCe6zxlR0ecSU5snDd?from=from_copylink
 // @Tags Sms
 // @Accept  json
 // @Produce json
@@ -45,6 +46,12 @@ func SendSMS(c *gin.Context) {
 	if err = opts.Validate(); err != nil {
 		return
 	}
+	for _, channel := range opts.Channels {
+		if channel == pkg.ChannelEmail && !email.Enabled() {
+			err = errors.New("email is not configured")
+			return
+		}
+	}
 
 	app, err = models.GetApplicationByIdForCandidate(opts.Aids[0])
 	if err != nil {
@@ -60,6 +67,9 @@ func SendSMS(c *gin.Context) {
 		err = fmt.Errorf("recruitment %s has already ended", r.Name)
 		return
 	}
+	if err = opts.ValidateSteps(r.GetPipeline()); err != nil {
+		return
+	}
 
 	user, err = grpc.GetUserInfoByUID(common.GetUID(c))
 	if err != nil {
@@ -67,8 +77,7 @@ func SendSMS(c *gin.Context) {
 	}
 
 	var errors []string
-	var smsBodys []*sms.SMSBody
-	var appUsersName []string
+	var msgs []*pkg.SMSMessage
 
 	for _, aid := range opts.Aids {
 		app, err = models.GetApplicationByIdForCandidate(aid)
@@ -106,16 +115,35 @@ func SendSMS(c *gin.Context) {
 			}
 		}
 
-		var smsBody *sms.SMSBody
-		smsBody, err = ApplySMSTemplate(opts, appUser, app, r)
+		var notification *pkg.Notification
+		notification, err = ApplyTemplate(opts, appUser, app, r)
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("set smsbody for user %s failed, error: %s", appUser.Name, err.Error()))
 			continue
 		}
 
-		smsBody.Phone = appUser.Phone
-		smsBodys = append(smsBodys, smsBody)
-		appUsersName = append(appUsersName, appUser.Name)
+		for _, channel := range opts.Channels {
+			msg := &pkg.SMSMessage{
+				ApplicationID: app.Uid,
+				OperatorID:    user.UID,
+				Channel:       channel,
+				Template:      notification.Template,
+				Params:        notification.Params,
+			}
+			switch channel {
+			case pkg.ChannelSMS:
+				msg.Phone = appUser.Phone
+				msg.TemplateID = pkg.SMSTemplateMap[notification.Template]
+			case pkg.ChannelEmail:
+				if appUser.Email == "" {
+					errors = append(errors, fmt.Sprintf("email of user %s is not set", appUser.Name))
+					continue
+				}
+				msg.Email = appUser.Email
+				msg.Subject = pkg.EmailSubject
+			}
+			msgs = append(msgs, msg)
+		}
 	}
 
 	if len(errors) != 0 {
@@ -123,26 +151,17 @@ func SendSMS(c *gin.Context) {
 		return
 	}
 
-	// send sms to candidate
-	for i, smsBody := range smsBodys {
-		zapx.Infof("smsbody : %v", *smsBody)
-		if _, err = sms.SendSMS(*smsBody); err != nil {
-			errors = append(errors, fmt.Sprintf("send sms for user %s failed, error: %s", appUsersName[i], err.Error()))
-			continue
-		}
-	}
-
-	if len(errors) != 0 {
-		err = fmt.Errorf("部分短信发送失败！\n%v", errors)
+	// sms are sent by the worker after written into the outbox
+	if err = models.CreateSMSMessages(msgs); err != nil {
 		return
 	}
+	jobs.NotifySMS()
 	return
 }
 
-func ApplySMSTemplate(smsRequest *pkg.SendSMSOpts, userInfo *pkg.UserDetail,
-	application *pkg.Application, recruitment *pkg.Recruitment) (*sms.SMSBody, error) {
-
-	var smsBody sms.SMSBody
+// ApplyTemplate choose the template and params of notification, which is sent by sms or email
+func ApplyTemplate(smsRequest *pkg.SendSMSOpts, userInfo *pkg.UserDetail,
+	application *pkg.Application, recruitment *pkg.Recruitment) (*pkg.Notification, error) {
 
 	suffix := " (请勿回复本短信)"
 	recruitmentName := utils.ConvertRecruitmentName(recruitment.Name)
@@ -183,11 +202,10 @@ func ApplySMSTemplate(smsRequest *pkg.SendSMSOpts, userInfo *pkg.UserDetail,
 					return nil, err
 				}
 				// {1}你好，请于{2}在启明学院亮胜楼{3}参加{4}，请准时到场。
-				smsBody = sms.SMSBody{
-					TemplateID: pkg.SMSTemplateMap[pkg.Interviews],
-					Params:     []string{userInfo.Name, formatTime, smsRequest.Place, pkg.EnToZhStepMap[smsRequest.Next]},
-				}
-				return &smsBody, nil
+				return &pkg.Notification{
+					Template: pkg.Interviews,
+					Params:   []string{userInfo.Name, formatTime, smsRequest.Place, pkg.EnToZhStepMap[smsRequest.Next]},
+				}, nil
 			//在线组面
 			case pkg.OnlineGroupInterview:
 				fallthrough
@@ -220,11 +238,10 @@ func ApplySMSTemplate(smsRequest *pkg.SendSMSOpts, userInfo *pkg.UserDetail,
 					return nil, err
 				}
 				// {1}你好，欢迎参加{2}{3}组在线群面，面试将于{4}进行，请在PC端点击腾讯会议参加面试，会议号{5}，并提前调试好摄像头和麦克风，祝你面试顺利。
-				smsBody = sms.SMSBody{
-					TemplateID: pkg.SMSTemplateMap[smsTemplate],
-					Params:     []string{userInfo.Name, recruitmentName, string(application.Group), formatTime, smsRequest.MeetingId},
-				}
-				return &smsBody, nil
+				return &pkg.Notification{
+					Template: smsTemplate,
+					Params:   []string{userInfo.Name, recruitmentName, string(application.Group), formatTime, smsRequest.MeetingId},
+				}, nil
 
 			//笔试
 			case pkg.WrittenTest:
@@ -264,11 +281,10 @@ func ApplySMSTemplate(smsRequest *pkg.SendSMSOpts, userInfo *pkg.UserDetail,
 				smsResMessage = smsRequest.Rest + suffix
 			}
 			// {1}你好，你通过了{2}{3}组{4}审核{5}
-			smsBody = sms.SMSBody{
-				TemplateID: pkg.SMSTemplateMap[pkg.PassSMS],
-				Params:     []string{userInfo.Name, recruitmentName, string(application.Group), pkg.EnToZhStepMap[smsRequest.Current], smsResMessage},
-			}
-			return &smsBody, nil
+			return &pkg.Notification{
+				Template: pkg.PassSMS,
+				Params:   []string{userInfo.Name, recruitmentName, string(application.Group), pkg.EnToZhStepMap[smsRequest.Current], smsResMessage},
+			}, nil
 		}
 	case pkg.Reject:
 		if !application.Rejected {
@@ -283,11 +299,10 @@ func ApplySMSTemplate(smsRequest *pkg.SendSMSOpts, userInfo *pkg.UserDetail,
 			smsResMessage = smsRequest.Rest + suffix
 		}
 		// {1}你好，你没有通过{2}{3}组{4}审核，请你{5}
-		smsBody = sms.SMSBody{
-			TemplateID: pkg.SMSTemplateMap[pkg.Delay],
-			Params:     []string{userInfo.Name, recruitmentName, string(application.Group), pkg.EnToZhStepMap[smsRequest.Current], smsResMessage},
-		}
-		return &smsBody, nil
+		return &pkg.Notification{
+			Template: pkg.Delay,
+			Params:   []string{userInfo.Name, recruitmentName, string(application.Group), pkg.EnToZhStepMap[smsRequest.Current], smsResMessage},
+		}, nil
 	}
 	return nil, errors.New("sms step is invalid")
 }
@@ -320,12 +335,43 @@ func SendCode(c *gin.Context) {
 		return
 	}
 
-	smsBody := sms.SMSBody{
+	// the code is sent at once instead of through the outbox, so that it's never saved
+	// and never retried after it expires
+	_, _, err = sms.Deliver(sms.SMSBody{
 		TemplateID: pkg.SMSTemplateMap[pkg.VerificationCode],
 		Phone:      user.Phone,
 		Params:     []string{smsCode},
+	})
+	return
+}
+
+// GetSMSMessages get sms sent to candidate
+// @Id get_sms_messages
+// @Summary Get sms messages of application
+// @Description Get sms sent to the candidate of application and their delivery status, can only be got by member of the corresponding group
+// @Tags Sms
+// @Accept  json
+// @Produce json
+// @Param aid query string true "application id"
+// @Success 200 {object} common.JSONResult{data=[]pkg.SMSMessage} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /sms/messages [get]
+func GetSMSMessages(c *gin.Context) {
+	var (
+		msgs []pkg.SMSMessage
+		err  error
+	)
+	defer func() { common.Resp(c, msgs, err) }()
+
+	opts := &pkg.GetSMSMessagesOpts{}
+	if err = c.ShouldBindQuery(opts); err != nil {
+		return
+	}
+
+	if err = checkMemberGroup(opts.Aid, common.GetUID(c)); err != nil {
+		return
 	}
 
-	_, err = sms.SendSMS(smsBody)
+	msgs, err = models.GetSMSMessagesByAid(opts.Aid)
 	return
 }
@@ -0,0 +1,239 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xylonx/zapx"
+	"go.uber.org/zap"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/internal/common"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/storage"
+	"UniqueRecruitmentBackend/pkg/upload"
+)
+
+const uploadURLExpire = 15 * time.Minute
+
+// CreateUpload get a presigned url to upload resume or answer directly to storage.
+// @Id create_upload.
+// @Summary get a presigned url to upload resume or answer directly to storage
+// @Description get a presigned PUT url to upload resume or answer without going through the backend, the request must carry the headers returned. The file is attached to application after confirm_upload. Can only be created by application's owner
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param	aid path string true "application id"
+// @Param pkg.CreateUploadOpts body pkg.CreateUploadOpts true "type, filename, content type and size of file"
+// @Success 200 {object} common.JSONResult{data=pkg.PresignedUpload} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/uploads [post]
+func CreateUpload(c *gin.Context) {
+	var (
+		app       *pkg.Application
+		r         *pkg.Recruitment
+		presigned *pkg.PresignedUpload
+		err       error
+	)
+	defer func() { common.Resp(c, presigned, err) }()
+
+	opts := &pkg.CreateUploadOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	if err = global.GetValidator().Check(upload.Purpose(opts.Type), opts.ContentType, opts.Size); err != nil {
+		return
+	}
+	if app, r, err = getUploadApplication(c, opts.Aid); err != nil {
+		return
+	}
+
+	// the file is renamed by its hash after confirmed
+	key := storage.StagingKey(uploadKeyPrefix(r, app) + upload.SanitizeFilename(opts.Filename))
+	url, err := global.GetStorage().PresignPut(c.Request.Context(), key, opts.ContentType, opts.Size, uploadURLExpire)
+	if err != nil {
+		return
+	}
+	presigned = &pkg.PresignedUpload{
+		Key:    key,
+		URL:    url,
+		Method: http.MethodPut,
+		Headers: map[string]string{
+			"Content-Type": opts.ContentType,
+		},
+		ExpiresAt: time.Now().Add(uploadURLExpire),
+	}
+}
+
+// ConfirmUpload attach the file uploaded directly to storage to application.
+// @Id confirm_upload.
+// @Summary attach the file uploaded by the presigned url to application
+// @Description check the file uploaded by the url got from create_upload like the uploaded resume and answer (type, size and virus scanning), and set it as the resume or answer of application. The file is renamed by the hash of its content. Can only be confirmed by application's owner
+// @Tags application
+// @Accept  json
+// @Produce  json
+// @Param	aid path string true "application id"
+// @Param pkg.ConfirmUploadOpts body pkg.ConfirmUploadOpts true "type and key of upload"
+// @Success 200 {object} common.JSONResult{data=pkg.Application} ""
+// @Failure 400 {object} common.JSONResult{} "code is not 0 and msg not empty"
+// @Router /applications/{aid}/uploads/confirmed [put]
+func ConfirmUpload(c *gin.Context) {
+	var (
+		app    *pkg.Application
+		r      *pkg.Recruitment
+		result *upload.Result
+		err    error
+	)
+	defer func() { common.Resp(c, app, err) }()
+
+	opts := &pkg.ConfirmUploadOpts{}
+	if err = c.ShouldBindUri(opts); err != nil {
+		return
+	}
+	if err = c.ShouldBindJSON(opts); err != nil {
+		return
+	}
+	if err = opts.Validate(); err != nil {
+		return
+	}
+
+	if app, r, err = getUploadApplication(c, opts.Aid); err != nil {
+		return
+	}
+
+	// the key must be staged for this application
+	key, ok := storage.PromotedKey(opts.Key)
+	filename, found := strings.CutPrefix(key, uploadKeyPrefix(r, app))
+	if !ok || !found || filename == "" || strings.Contains(filename, "/") {
+		err = errors.New("request param error, key is not uploaded for this application")
+		return
+	}
+
+	store := global.GetStorage()
+	result, err = validateStagedFile(c, upload.Purpose(opts.Type), opts.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		err = errors.New("the file is not uploaded yet")
+		return
+	}
+	if err != nil {
+		if errors.Is(err, upload.ErrContentType) || errors.Is(err, upload.ErrFileTooLarge) || errors.Is(err, upload.ErrInfected) {
+			if errDelete := store.Delete(c.Request.Context(), opts.Key); errDelete != nil {
+				zapx.Warn("delete refused upload failed", zap.String("key", opts.Key), zap.Error(errDelete))
+			}
+		}
+		return
+	}
+
+	// stage the file again with the name of its hash
+	stagingKey := storage.StagingKey(uploadKeyPrefix(r, app) + result.Filename())
+	if err = store.Copy(c.Request.Context(), opts.Key, stagingKey); err != nil {
+		return
+	}
+	if errDelete := store.Delete(c.Request.Context(), opts.Key); errDelete != nil {
+		zapx.Warn("delete confirmed upload failed", zap.String("key", opts.Key), zap.Error(errDelete))
+	}
+
+	var resume *pkg.ResumeVersion
+	if opts.Type == pkg.UploadResume {
+		// the key is set after promoted
+		resume = newResumeVersion("", result)
+	}
+	err = models.AttachUploadedFile(app, opts.Type, stagingKey, resume)
+}
+
+// validateFile checks the uploaded file by the policy of purpose
+func validateFile(c *gin.Context, purpose upload.Purpose, file *multipart.FileHeader) (*upload.Result, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result, err := global.GetValidator().Validate(c.Request.Context(), purpose, file.Filename, f)
+	if err != nil {
+		return nil, err
+	}
+	// save the file with the sniffed type instead of the one claimed by user
+	file.Header.Set("Content-Type", result.ContentType)
+	return result, nil
+}
+
+// newResumeVersion describes the valid resume saved to key
+func newResumeVersion(key string, result *upload.Result) *pkg.ResumeVersion {
+	return &pkg.ResumeVersion{
+		Key:         key,
+		Hash:        result.Hash,
+		Size:        result.Size,
+		ContentType: result.ContentType,
+	}
+}
+
+// validateStagedFile downloads the file uploaded directly to storage into a temporary file and checks it
+func validateStagedFile(c *gin.Context, purpose upload.Purpose, key string) (*upload.Result, error) {
+	obj, err := global.GetStorage().Get(c.Request.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+
+	policy := global.GetValidator().Policies[purpose]
+	if obj.ContentLength > policy.MaxSize {
+		return nil, upload.ErrFileTooLarge
+	}
+
+	f, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if _, err = io.Copy(f, io.LimitReader(obj.Body, policy.MaxSize+1)); err != nil {
+		return nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return global.GetValidator().Validate(c.Request.Context(), purpose, key, f)
+}
+
+// getUploadApplication get the application and its recruitment, and check the candidate can upload files to it
+func getUploadApplication(c *gin.Context, aid string) (*pkg.Application, *pkg.Recruitment, error) {
+	app, err := models.GetApplicationByIdForCandidate(aid)
+	if err != nil {
+		return nil, nil, err
+	}
+	if app.CandidateID != common.GetUID(c) {
+		return nil, nil, errors.New("you can't upload files to other's application")
+	}
+	if app.Abandoned || app.Rejected {
+		return nil, nil, fmt.Errorf("you have been abandoned / rejected")
+	}
+
+	r, err := models.GetRecruitmentById(app.RecruitmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app, r, nil
+}
+
+// uploadKeyPrefix is the prefix of files of application,
+// file path example: 2023秋(rname)/web(group)/wwb(uid)/filename
+func uploadKeyPrefix(r *pkg.Recruitment, app *pkg.Application) string {
+	return fmt.Sprintf("%s/%s/%s/", r.Name, app.Group, app.CandidateID)
+}
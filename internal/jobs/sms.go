@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/xylonx/zapx"
+	"go.uber.org/zap"
+
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/email"
+	"UniqueRecruitmentBackend/pkg/sms"
+)
+
+const (
+	smsPollInterval = 5 * time.Second
+	smsBatchSize    = 20
+	smsLease        = time.Minute // longer than a request to open platform
+	smsMaxAttempts  = 8
+	smsBaseBackoff  = 30 * time.Second
+	smsMaxBackoff   = time.Hour
+)
+
+var smsWakeup = make(chan struct{}, 1)
+
+// NotifySMS wakes up the sms worker after sms are written into the outbox
+func NotifySMS() {
+	select {
+	case smsWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// RunSMSWorker sends the sms and email in the outbox until ctx is done
+func RunSMSWorker(ctx context.Context) {
+	ticker := time.NewTicker(smsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-smsWakeup:
+		}
+
+		for ctx.Err() == nil {
+			msgs, err := models.ClaimSMSMessages(smsBatchSize, smsLease)
+			if err != nil {
+				zapx.Error("claim sms messages failed", zap.Error(err))
+				break
+			}
+			for i := range msgs {
+				deliverSMS(&msgs[i])
+			}
+			if len(msgs) < smsBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliver sends the message by its channel
+func deliver(msg *pkg.SMSMessage) (status int, response string, err error) {
+	switch msg.Channel {
+	case pkg.ChannelEmail:
+		return email.Deliver(email.EmailBody{
+			To:       msg.Email,
+			Subject:  msg.Subject,
+			Template: msg.Template,
+			Params:   msg.Params,
+		})
+	default:
+		return sms.Deliver(sms.SMSBody{
+			Phone:      msg.Phone,
+			TemplateID: msg.TemplateID,
+			Params:     msg.Params,
+		})
+	}
+}
+
+func deliverSMS(msg *pkg.SMSMessage) {
+	status, response, err := deliver(msg)
+
+	msg.Attempts++
+	msg.ProviderStatus = status
+	msg.ProviderResponse = response
+	if err == nil {
+		now := time.Now()
+		msg.Status = pkg.SMSSent
+		msg.SentAt = &now
+		msg.LastError = ""
+	} else {
+		msg.LastError = err.Error()
+		if msg.Attempts >= smsMaxAttempts {
+			msg.Status = pkg.SMSFailed
+		} else {
+			msg.NextAttemptAt = time.Now().Add(smsBackoff(msg.Attempts))
+		}
+		zapx.Warn("send message failed", zap.String("uid", msg.Uid), zap.String("channel", string(msg.Channel)), zap.Int("attempts", msg.Attempts), zap.Error(err))
+	}
+
+	if err := models.UpdateSMSAttempt(msg); err != nil {
+		zapx.Error("save sms attempt failed", zap.String("uid", msg.Uid), zap.Error(err))
+	}
+}
+
+// smsBackoff doubles the delay after each failed attempt
+func smsBackoff(attempts int) time.Duration {
+	delay := smsBaseBackoff
+	for i := 1; i < attempts && delay < smsMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > smsMaxBackoff {
+		delay = smsMaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/xylonx/zapx"
+	"go.uber.org/zap"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/internal/models"
+	"UniqueRecruitmentBackend/pkg/storage"
+)
+
+const (
+	storageSweepInterval = time.Hour
+	stagingTTL           = time.Hour      // longer than an upload and its transaction
+	orphanTTL            = 24 * time.Hour // files replaced recently are kept for a while
+)
+
+// RunStorageSweeper deletes the staging files and the application files no application references until ctx is done
+func RunStorageSweeper(ctx context.Context) {
+	ticker := time.NewTicker(storageSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		store := global.GetStorage()
+		if err := sweepStaging(ctx, store); err != nil {
+			zapx.Error("sweep staging files failed", zap.Error(err))
+		}
+		if err := sweepOrphans(ctx, store); err != nil {
+			zapx.Error("sweep orphaned files failed", zap.Error(err))
+		}
+	}
+}
+
+// sweepStaging promotes the staged files whose promotion failed after the application was saved,
+// and deletes the other staged files after stagingTTL
+func sweepStaging(ctx context.Context, store storage.Storage) error {
+	objects, err := store.List(ctx, storage.StagingPrefix)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if key, ok := storage.PromotedKey(object.Key); ok {
+			keys = append(keys, key)
+		}
+	}
+	referenced, err := models.GetReferencedFiles(keys)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, object := range objects {
+		key, ok := storage.PromotedKey(object.Key)
+		if ok && referenced[key] {
+			_, err = store.Stat(ctx, key)
+			if errors.Is(err, storage.ErrNotFound) {
+				if err = storage.Promote(ctx, store, object.Key); err != nil {
+					zapx.Warn("promote staged file failed", zap.String("key", object.Key), zap.Error(err))
+				}
+				continue
+			}
+			if err != nil {
+				zapx.Warn("stat file failed", zap.String("key", key), zap.Error(err))
+				continue
+			}
+		}
+		if now.Sub(object.LastModified) < stagingTTL {
+			continue
+		}
+		if err = store.Delete(ctx, object.Key); err != nil {
+			zapx.Warn("delete staged file failed", zap.String("key", object.Key), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// sweepOrphans deletes the resumes and answers which are no longer referenced by any application,
+// such as the old answer after the candidate uploads another one. Old versions of resumes are kept.
+// Application files are keyed by {recruitment}/{group}/{candidate}/{filename},
+// the recruitment files keyed by {recruitment}/{group}/{type}.pdf are not touched
+func sweepOrphans(ctx context.Context, store storage.Storage) error {
+	recruitments, err := models.GetAllRecruitment()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, r := range recruitments {
+		objects, err := store.List(ctx, r.Name+"/")
+		if err != nil {
+			return err
+		}
+		var keys []string
+		for _, object := range objects {
+			if strings.Count(object.Key, "/") == 3 && now.Sub(object.LastModified) >= orphanTTL {
+				keys = append(keys, object.Key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		referenced, err := models.GetReferencedFiles(keys)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if referenced[key] {
+				continue
+			}
+			if err = store.Delete(ctx, key); err != nil {
+				zapx.Warn("delete orphaned file failed", zap.String("key", key), zap.Error(err))
+				continue
+			}
+			zapx.Info("orphaned file is deleted", zap.String("key", key))
+		}
+	}
+	return nil
+}
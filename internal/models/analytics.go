@@ -0,0 +1,125 @@
+package models
+
+import (
+	"time"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+// GetRecruitmentAnalytics report the funnel of recruitment, per group and in total, the time applications spent in each stage
+// and the breakdown by grade and institute. The previous recruitments (the compare ones before it by beginning) only have
+// the summary and funnel.
+func GetRecruitmentAnalytics(rid string, compare int) (*pkg.RecruitmentAnalytics, error) {
+	r, err := GetRecruitmentById(rid)
+	if err != nil {
+		return nil, err
+	}
+	analytics, err := getRecruitmentAnalytics(r)
+	if err != nil {
+		return nil, err
+	}
+	analytics.GroupFunnels = make(map[pkg.Group][]pkg.FunnelStage)
+	for group := range analytics.groupApps {
+		analytics.GroupFunnels[group] = pkg.BuildFunnel(r.GetPipeline(), analytics.groupApps[group], analytics.durations)
+	}
+	analytics.Grades = pkg.Breakdown(analytics.apps, func(app *pkg.Application) string { return app.Grade })
+	analytics.Institutes = pkg.Breakdown(analytics.apps, func(app *pkg.Application) string { return app.Institute })
+
+	if compare == 0 {
+		return &analytics.RecruitmentAnalytics, nil
+	}
+	recruitments, err := GetAllRecruitment()
+	if err != nil {
+		return nil, err
+	}
+	for i := range recruitments {
+		if len(analytics.Previous) == compare {
+			break
+		}
+		if !recruitments[i].Beginning.Before(r.Beginning) {
+			continue
+		}
+		previous, err := getRecruitmentAnalytics(&recruitments[i])
+		if err != nil {
+			return nil, err
+		}
+		analytics.Previous = append(analytics.Previous, previous.RecruitmentAnalytics)
+	}
+	return &analytics.RecruitmentAnalytics, nil
+}
+
+type recruitmentAnalytics struct {
+	pkg.RecruitmentAnalytics
+	apps      []pkg.Application
+	groupApps map[pkg.Group][]pkg.Application
+	durations map[string]map[pkg.Step]time.Duration
+}
+
+// getRecruitmentAnalytics load the applications and their events, and fill the summary and funnel
+func getRecruitmentAnalytics(r *pkg.Recruitment) (*recruitmentAnalytics, error) {
+	db := global.GetDB()
+	var apps []pkg.Application
+	if err := db.Model(&pkg.Application{}).
+		Select("uid, \"createdAt\", \"group\", grade, institute, step, abandoned, rejected").
+		Where("\"recruitmentId\" = ?", r.Uid).
+		Find(&apps).Error; err != nil {
+		return nil, err
+	}
+
+	var events []pkg.ApplicationEvent
+	if err := db.Model(&pkg.ApplicationEvent{}).
+		Joins("JOIN applications ON applications.uid = application_events.\"applicationId\"").
+		Where("applications.\"recruitmentId\" = ? AND application_events.action IN ?", r.Uid,
+			[]Action{ActionCreate, pkg.ActionSetStep, pkg.ActionAbandon, pkg.ActionReject, pkg.ActionRestore}).
+		Order("application_events.\"createdAt\"").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	analytics := &recruitmentAnalytics{
+		RecruitmentAnalytics: pkg.RecruitmentAnalytics{Rid: r.Uid, Name: r.Name, Total: len(apps)},
+		apps:                 apps,
+		groupApps:            make(map[pkg.Group][]pkg.Application),
+		durations:            pkg.StepDurations(apps, stepEvents(events)),
+	}
+	for _, app := range apps {
+		switch {
+		case app.Abandoned:
+			analytics.Abandoned++
+		case app.Rejected:
+			analytics.Rejected++
+		case app.Step == pkg.Pass:
+			analytics.Passed++
+		}
+		analytics.groupApps[app.Group] = append(analytics.groupApps[app.Group], app)
+	}
+	analytics.Funnel = pkg.BuildFunnel(r.GetPipeline(), apps, analytics.durations)
+	return analytics, nil
+}
+
+// stepEvents translate the events of applications into the changes of their steps
+func stepEvents(events []pkg.ApplicationEvent) []pkg.StepEvent {
+	kinds := map[Action]pkg.StepEventKind{
+		ActionCreate:      pkg.StepEntered,
+		pkg.ActionSetStep: pkg.StepMoved,
+		pkg.ActionAbandon: pkg.StepClosed,
+		pkg.ActionReject:  pkg.StepClosed,
+		pkg.ActionRestore: pkg.StepReopened,
+	}
+	stepEvents := make([]pkg.StepEvent, 0, len(events))
+	for _, event := range events {
+		kind, ok := kinds[Action(event.Action)]
+		if !ok {
+			continue
+		}
+		stepEvents = append(stepEvents, pkg.StepEvent{
+			ApplicationID: event.ApplicationID,
+			At:            event.CreatedAt,
+			Kind:          kind,
+			From:          event.From,
+			To:            event.To,
+		})
+	}
+	return stepEvents
+}
@@ -3,17 +3,27 @@ package models
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/xylonx/zapx"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"UniqueRecruitmentBackend/global"
 	"UniqueRecruitmentBackend/pkg"
 )
 
-func CreateApplication(opts *pkg.CreateAppOpts, uid string, filePath string) (*pkg.Application, error) {
+// CreateApplication saves the application, resume is nil if the candidate doesn't upload one,
+// otherwise it becomes the first version
+func CreateApplication(opts *pkg.CreateAppOpts, uid string, resume *pkg.ResumeVersion) (*pkg.Application, error) {
 	db := global.GetDB()
+	filePath := ""
+	if resume != nil {
+		filePath = resume.Key
+	}
 	app := &pkg.Application{
 		Grade:         opts.Grade,
 		Institute:     opts.Institute,
@@ -31,22 +41,32 @@ func CreateApplication(opts *pkg.CreateAppOpts, uid string, filePath string) (*p
 		RecruitmentID: opts.RecruitmentID,
 	}
 
+	// upload resume to staging, it's promoted after the application is saved
+	var staged []string
+	if filePath != "" {
+		stagingKey, err := stageFile(filePath, opts.Resume)
+		if err != nil {
+			zapx.Error("upload resume to storage failed", zap.String("filepath", filePath), zap.Error(err))
+			return nil, err
+		}
+		staged = append(staged, stagingKey)
+	}
+
 	if err := db.Transaction(func(tx *gorm.DB) error {
 		if errdb := tx.Create(app).Error; errdb != nil {
 			return errdb
 		}
-		//upload resume to COS
-		if filePath != "" {
-			errfile := global.UpLoadAndSaveFileToCos(opts.Resume, filePath)
-			if errfile != nil {
-				zapx.Error("upload resume to tos failed", zap.String("filepath", filePath))
-				return errfile
+		if resume != nil {
+			if errdb := createResumeVersion(tx, app.Uid, resume); errdb != nil {
+				return errdb
 			}
 		}
-		return nil
+		return recordEvent(tx, app.Uid, uid, ActionCreate, "", app.Step, "")
 	}); err != nil {
+		discardFiles(staged)
 		return nil, err
 	}
+	promoteFiles(staged)
 	return app, nil
 }
 
@@ -79,8 +99,13 @@ func GetApplicationById(aid string) (*pkg.Application, error) {
 	return &a, nil
 }
 
-func UpdateApplication(opts *pkg.UpdateAppOpts, resumeFilePath string, answerFilePath string) (*pkg.Application, error) {
+// UpdateApplication updates the application, resume is saved as a new version if opts.Resume is uploaded
+func UpdateApplication(opts *pkg.UpdateAppOpts, resume *pkg.ResumeVersion, answerFilePath string) (*pkg.Application, error) {
 	db := global.GetDB()
+	resumeFilePath := ""
+	if resume != nil {
+		resumeFilePath = resume.Key
+	}
 
 	var a pkg.Application
 	if err := db.Model(&pkg.Application{}).
@@ -120,6 +145,26 @@ func UpdateApplication(opts *pkg.UpdateAppOpts, resumeFilePath string, answerFil
 		a.Answer = answerFilePath
 	}
 
+	// upload files to staging, they are promoted after the application is saved
+	var staged []string
+	if opts.Resume != nil {
+		stagingKey, err := stageFile(resumeFilePath, opts.Resume)
+		if err != nil {
+			zapx.Error("upload resume to storage failed", zap.String("filepath", resumeFilePath), zap.Error(err))
+			return nil, err
+		}
+		staged = append(staged, stagingKey)
+	}
+	if opts.Answer != nil {
+		stagingKey, err := stageFile(answerFilePath, opts.Answer)
+		if err != nil {
+			zapx.Error("upload answer to storage failed", zap.String("filepath", answerFilePath), zap.Error(err))
+			discardFiles(staged)
+			return nil, err
+		}
+		staged = append(staged, stagingKey)
+	}
+
 	if err := db.Transaction(func(tx *gorm.DB) error {
 		if errdb := tx.Updates(&a).Error; errdb != nil {
 			return errdb
@@ -129,50 +174,53 @@ func UpdateApplication(opts *pkg.UpdateAppOpts, resumeFilePath string, answerFil
 			return errdb
 		}
 
-		//upload resume to COS
 		if opts.Resume != nil {
-			if errfile := global.UpLoadAndSaveFileToCos(opts.Resume, resumeFilePath); errfile != nil {
-				zapx.Error("upload resume to tos failed", zap.String("filepath", resumeFilePath))
-				return errfile
+			if errdb := createResumeVersion(tx, a.Uid, resume); errdb != nil {
+				return errdb
 			}
 		}
 
-		if opts.Answer != nil {
-			if errfile := global.UpLoadAndSaveFileToCos(opts.Answer, answerFilePath); errfile != nil {
-				zapx.Error("upload answer to tos failed", zap.String("filepath", answerFilePath))
-				return errfile
-			}
-		}
-		return nil
+		return recordEvent(tx, a.Uid, a.CandidateID, ActionUpdate, a.Step, a.Step, "")
 	}); err != nil {
+		discardFiles(staged)
 		return nil, err
 	}
+	promoteFiles(staged)
 	return &a, nil
 }
 
-func DeleteApplication(aid string) error {
+func DeleteApplication(aid string, operator *pkg.Operator) error {
 	db := global.GetDB()
-	return db.Where("uid = ?", aid).Delete(&pkg.Application{}).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		var app pkg.Application
+		if errdb := tx.Where("uid = ?", aid).First(&app).Error; errdb != nil {
+			return errdb
+		}
+		if errdb := tx.Delete(&app).Error; errdb != nil {
+			return errdb
+		}
+		return recordEvent(tx, aid, operator.UID, ActionDelete, app.Step, "", "")
+	})
 }
 
-func AbandonApplication(aid string) error {
-	db := global.GetDB()
-	application, err := GetApplicationByIdForCandidate(aid)
-	if err != nil {
-		return err
-	}
-	application.Abandoned = true
-	return db.Updates(&application).Error
+func AbandonApplication(aid string, reason string, operator *pkg.Operator) error {
+	return transitApplication(aid, pkg.ActionAbandon, "", "", reason, operator, map[string]interface{}{
+		"abandoned": true,
+	})
 }
 
-func RejectApplication(aid string) error {
-	db := global.GetDB()
-	application, err := GetApplicationByIdForCandidate(aid)
-	if err != nil {
-		return err
-	}
-	application.Rejected = true
-	return db.Updates(&application).Error
+func RejectApplication(aid string, reason string, operator *pkg.Operator) error {
+	return transitApplication(aid, pkg.ActionReject, "", "", reason, operator, map[string]interface{}{
+		"rejected": true,
+	})
+}
+
+// RestoreApplication cancel the abandonment/rejection of the application
+func RestoreApplication(aid string, reason string, operator *pkg.Operator) error {
+	return transitApplication(aid, pkg.ActionRestore, "", "", reason, operator, map[string]interface{}{
+		"abandoned": false,
+		"rejected":  false,
+	})
 }
 
 func GetApplicationsByRid(rid string) ([]pkg.Application, error) {
@@ -184,28 +232,255 @@ func GetApplicationsByRid(rid string) ([]pkg.Application, error) {
 	return recruitment.Applications, nil
 }
 
-func SetApplicationStepById(opts *pkg.SetAppStepOpts) error {
+// the averages are float8 so that they round-trip through the cursor exactly,
+// only the comments the viewer can see are counted so that they don't give away the hidden evaluations
+const (
+	averageEvaluationSQL = `(SELECT AVG(comments.evaluation)::float8 FROM comments WHERE comments."applicationId" = applications.uid AND ` + visibleCommentSQL + `)`
+	averageScoreSQL      = `(SELECT AVG(comments.score)::float8 FROM comments WHERE comments."applicationId" = applications.uid AND ` + visibleCommentSQL + `)`
+)
+
+// ListApplications query a page of applications in the recruitment by keyset pagination,
+// the next cursor is nil if it's the last page. Comments and interview selections are not loaded.
+// The averages, sort and evaluation filter only take the comments the viewer can see.
+func ListApplications(opts *pkg.ListApplicationsOpts, viewer *pkg.CommentViewer) ([]pkg.Application, *pkg.ApplicationCursor, error) {
+	db := global.GetDB()
+	args := commentViewerArgs(viewer)
+
+	// applications without comment are the last in both orders
+	sortExpr := `applications."createdAt"`
+	switch opts.Sort {
+	case pkg.SortByEvaluation:
+		if opts.Desc {
+			sortExpr = "COALESCE(" + averageEvaluationSQL + ", -1)"
+		} else {
+			sortExpr = "COALESCE(" + averageEvaluationSQL + ", 99)"
+		}
+	case pkg.SortByScore:
+		if opts.Desc {
+			sortExpr = "COALESCE(" + averageScoreSQL + ", -1)"
+		} else {
+			sortExpr = "COALESCE(" + averageScoreSQL + ", 999)"
+		}
+	}
+	direction, compare := "ASC", ">"
+	if opts.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	query := db.Model(&pkg.Application{}).
+		Select("applications.*, "+averageEvaluationSQL+" AS \"averageEvaluation\", "+averageScoreSQL+" AS score", args).
+		Preload("InterviewAllocationsGroup").
+		Preload("InterviewAllocationsTeam").
+		Where("applications.\"recruitmentId\" = ?", opts.Rid)
+
+	if len(opts.Groups) != 0 {
+		query = query.Where("applications.\"group\" IN ?", opts.Groups)
+	}
+	if len(opts.Steps) != 0 {
+		query = query.Where("applications.step IN ?", opts.Steps)
+	}
+	if opts.Rejected != nil {
+		query = query.Where("applications.rejected = ?", *opts.Rejected)
+	}
+	if opts.Abandoned != nil {
+		query = query.Where("applications.abandoned = ?", *opts.Abandoned)
+	}
+	if len(opts.Grades) != 0 {
+		query = query.Where("applications.grade IN ?", opts.Grades)
+	}
+	if opts.Institute != "" {
+		query = query.Where("applications.institute = ?", opts.Institute)
+	}
+	if opts.IsQuick != nil {
+		query = query.Where("applications.\"isQuick\" = ?", *opts.IsQuick)
+	}
+	if opts.HasResume != nil {
+		query = query.Where("(COALESCE(applications.resume, '') <> '') = ?", *opts.HasResume)
+	}
+	if len(opts.Evaluations) != 0 {
+		args["evaluations"] = opts.Evaluations
+		query = query.Where(`EXISTS (SELECT 1 FROM comments WHERE comments."applicationId" = applications.uid AND comments.evaluation IN @evaluations AND `+visibleCommentSQL+`)`, args)
+	}
+
+	if opts.Cursor != "" {
+		cursor, err := pkg.DecodeApplicationCursor(opts.Cursor, opts.Sort, opts.Desc)
+		if err != nil {
+			return nil, nil, err
+		}
+		var key interface{} = cursor.Key
+		if opts.Sort == pkg.SortByCreatedAt {
+			if key, err = time.Parse(time.RFC3339Nano, cursor.Key); err != nil {
+				return nil, nil, errors.New("request param error, cursor is invalid")
+			}
+		} else if key, err = strconv.ParseFloat(cursor.Key, 64); err != nil {
+			return nil, nil, errors.New("request param error, cursor is invalid")
+		}
+		args["cursorKey"] = key
+		args["cursorUid"] = cursor.Uid
+		query = query.Where(fmt.Sprintf("(%s, applications.uid) %s (@cursorKey, @cursorUid)", sortExpr, compare), args)
+	}
+
+	var apps []pkg.Application
+	if err := query.
+		Clauses(clause.OrderBy{Expression: clause.NamedExpr{
+			SQL:  fmt.Sprintf("%s %s, applications.uid %s", sortExpr, direction, direction),
+			Vars: []interface{}{args},
+		}}).
+		Limit(opts.Limit + 1).
+		Find(&apps).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(apps) <= opts.Limit {
+		return apps, nil, nil
+	}
+
+	apps = apps[:opts.Limit]
+	last := apps[len(apps)-1]
+	next := &pkg.ApplicationCursor{Sort: opts.Sort, Desc: opts.Desc, Uid: last.Uid}
+	if opts.Sort == pkg.SortByCreatedAt {
+		next.Key = last.CreatedAt.Format(time.RFC3339Nano)
+		return apps, next, nil
+	}
+	average, missing := last.AverageEvaluation, "99"
+	if opts.Sort == pkg.SortByScore {
+		average, missing = last.Score, "999"
+	}
+	switch {
+	case average != nil:
+		next.Key = strconv.FormatFloat(*average, 'g', -1, 64)
+	case opts.Desc:
+		next.Key = "-1"
+	default:
+		next.Key = missing
+	}
+	return apps, next, nil
+}
+
+func SetApplicationStepById(opts *pkg.SetAppStepOpts, operator *pkg.Operator) error {
+	return transitApplication(opts.Aid, pkg.ActionSetStep, opts.From, opts.To, opts.Reason, operator, map[string]interface{}{
+		"step": opts.To,
+	})
+}
+
+// SetApplicationsStep move applications from one step to another atomically
+func SetApplicationsStep(opts *pkg.SetAppsStepOpts, operator *pkg.Operator) error {
+	return transitApplications(opts.Aids, pkg.ActionSetStep, opts.From, opts.To, opts.Reason, operator, map[string]interface{}{
+		"step": opts.To,
+	})
+}
+
+// RejectApplications reject applications atomically
+func RejectApplications(opts *pkg.RejectAppsOpts, operator *pkg.Operator) error {
+	return transitApplications(opts.Aids, pkg.ActionReject, "", "", opts.Reason, operator, map[string]interface{}{
+		"rejected": true,
+	})
+}
+
+func GetApplicationsByIds(aids []string) ([]pkg.Application, error) {
+	db := global.GetDB()
+	var apps []pkg.Application
+	if err := db.Model(&pkg.Application{}).
+		Where("uid IN ?", aids).
+		Find(&apps).Error; err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// transitApplication check the transition by the state machine, then apply the updates and record the event.
+// from is the step the operator sees, empty means the current step of application.
+func transitApplication(aid string, action Action, from, to pkg.Step, reason string, operator *pkg.Operator, updates map[string]interface{}) error {
 	db := global.GetDB()
-	app, err := GetApplicationByIdForCandidate(opts.Aid)
+	app, err := GetApplicationByIdForCandidate(aid)
 	if err != nil {
 		return err
 	}
+	r, err := GetRecruitmentById(app.RecruitmentID)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		return transitApplicationTx(tx, app, r.GetPipeline(), action, from, to, reason, operator, updates)
+	})
+}
 
-	if app.Step != opts.From {
-		return errors.New("the step doesn't match")
+// transitApplications apply the same transition to applications in one transaction,
+// none of them is changed if any transition is refused, the error lists every refused application
+func transitApplications(aids []string, action Action, from, to pkg.Step, reason string, operator *pkg.Operator, updates map[string]interface{}) error {
+	db := global.GetDB()
+	apps, err := GetApplicationsByIds(aids)
+	if err != nil {
+		return err
 	}
-	if app.Abandoned || app.Rejected {
-		return fmt.Errorf("application of %s has already been abandoned/reject", app.Uid)
+	appMap := make(map[string]*pkg.Application)
+	for i := range apps {
+		appMap[apps[i].Uid] = &apps[i]
 	}
 
-	return db.Model(&pkg.Application{}).
-		Where("uid = ?", app.Uid).
-		Updates(map[string]interface{}{
-			"step": opts.To,
-		}).Error
+	pipelines := make(map[string]pkg.Pipeline)
+	var errs []string
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, aid := range aids {
+			app, ok := appMap[aid]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("application %s is not found", aid))
+				continue
+			}
+
+			pipeline, ok := pipelines[app.RecruitmentID]
+			if !ok {
+				r, errDb := GetRecruitmentById(app.RecruitmentID)
+				if errDb != nil {
+					return errDb
+				}
+				pipeline = r.GetPipeline()
+				pipelines[app.RecruitmentID] = pipeline
+			}
+
+			if errTransit := transitApplicationTx(tx, app, pipeline, action, from, to, reason, operator, updates); errTransit != nil {
+				if _, ok := errTransit.(*pkg.TransitionError); !ok {
+					// the transaction is aborted by database error
+					return errTransit
+				}
+				errs = append(errs, errTransit.Error())
+			}
+		}
+		if len(errs) != 0 {
+			return fmt.Errorf("none of the applications are changed\n%v", errs)
+		}
+		return nil
+	})
 }
 
-func SetApplicationInterviewTime(opts *pkg.SetAppInterviewTimeOpts) error {
+// transitApplicationTx is the transition of one application in the transaction.
+// The update only succeeds if the application isn't changed by others in the meantime.
+func transitApplicationTx(tx *gorm.DB, app *pkg.Application, pipeline pkg.Pipeline, action Action, from, to pkg.Step, reason string, operator *pkg.Operator, updates map[string]interface{}) error {
+	if from == "" {
+		from = app.Step
+	}
+	if err := pkg.CheckTransition(app, pipeline, action, from, to, operator); err != nil {
+		return err
+	}
+
+	result := tx.Model(&pkg.Application{}).
+		Where("uid = ? AND step = ? AND abandoned = ? AND rejected = ?", app.Uid, app.Step, app.Abandoned, app.Rejected).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &pkg.TransitionError{Aid: app.Uid, Action: action, From: from, To: to, Err: pkg.ErrStepMismatch}
+	}
+
+	eventTo := to
+	if eventTo == "" {
+		eventTo = from
+	}
+	return recordEvent(tx, app.Uid, operator.UID, action, from, eventTo, reason)
+}
+
+func SetApplicationInterviewTime(opts *pkg.SetAppInterviewTimeOpts, operator *pkg.Operator) error {
 	db := global.GetDB()
 	if _, err := GetInterviewById(opts.InterviewId); err != nil {
 		return err
@@ -216,21 +491,40 @@ func SetApplicationInterviewTime(opts *pkg.SetAppInterviewTimeOpts) error {
 		return err
 	}
 
-	switch opts.InterviewType {
-	case pkg.InGroup:
-		err = db.Model(&pkg.Application{}).
-			Where("uid = ?", application.Uid).
-			Update("\"interviewAllocationsGroupId\"", opts.InterviewId).Error
-	case pkg.InTeam:
-		err = db.Model(&pkg.Application{}).
-			Where("uid = ?", application.Uid).
-			Update("\"interviewAllocationsTeamId\"", opts.InterviewId).Error
+	return db.Transaction(func(tx *gorm.DB) error {
+		return setApplicationInterviewTimeTx(tx, application, opts.InterviewType, opts.InterviewId, operator)
+	})
+}
+
+// setApplicationInterviewTimeTx set the interview of application, the interview row is locked
+// so that it's serialized with the allocation of interviews
+func setApplicationInterviewTimeTx(tx *gorm.DB, application *pkg.Application, interviewType pkg.GroupOrTeam, iid string, operator *pkg.Operator) error {
+	if errDb := tx.Model(&pkg.Interview{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("uid = ?", iid).
+		First(&pkg.Interview{}).Error; errDb != nil {
+		return errDb
 	}
-	return err
+	if errDb := tx.Model(&pkg.Application{}).
+		Where("uid = ?", application.Uid).
+		Update(allocationColumn(interviewType), iid).Error; errDb != nil {
+		return errDb
+	}
+	return recordEvent(tx, application.Uid, operator.UID, ActionSetInterviewTime, application.Step, application.Step,
+		interviewEventReason(interviewType, iid))
+}
+
+func interviewEventReason(interviewType pkg.GroupOrTeam, iid string) string {
+	return fmt.Sprintf("%s interview: %s", interviewType, iid)
 }
 
+// UpdateInterviewSelection replace the interview selections of the application.
+// The seat of an interview is taken by a conditional update, which is checked again by postgres
+// after the row lock is released, so concurrent selections can't exceed the slot number.
 func UpdateInterviewSelection(app *pkg.Application, interviews []pkg.Interview, iidsToAdd, iidsToDel []string) error {
 	db := global.GetDB()
+	// lock rows in the same order to avoid deadlock between concurrent selections
+	sort.Strings(iidsToAdd)
 	err := db.Transaction(func(tx *gorm.DB) error {
 		if errDb := tx.Model(app).
 			Association("InterviewSelections").
@@ -243,27 +537,33 @@ func UpdateInterviewSelection(app *pkg.Application, interviews []pkg.Interview,
 			Append(interviews); errDb != nil {
 			return errDb
 		}
-		//app.InterviewSelections = interviews
-		//if errDb := tx.Save(app).Error; errDb != nil {
-		//	return errDb
-		//}
-
-		//if errDb := tx.Model(&pkg.Interview{}).
-		//	Where("uid IN ?", iidsToAdd).
-		//	Updates(map[string]interface{}{
-		//		"selectNumber": gorm.Expr("\"selectNumber\" + ?", 1),
-		//	}).Error; errDb != nil {
-		//	return errDb
-		//}
-		//
-		//if errDb := tx.Model(&pkg.Interview{}).
-		//	Where("uid IN ?", iidsToDel).
-		//	Updates(map[string]interface{}{
-		//		"selectNumber": gorm.Expr("\"selectNumber\" - ?", 1),
-		//	}).Error; errDb != nil {
-		//	return errDb
-		//}
-		return nil
+
+		for _, iid := range iidsToAdd {
+			result := tx.Model(&pkg.Interview{}).
+				Where("uid = ?", iid).
+				Where("\"slotNumber\" = 0 OR \"selectNumber\" < \"slotNumber\"").
+				Updates(map[string]interface{}{
+					"\"selectNumber\"": gorm.Expr("\"selectNumber\" + ?", 1),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("interview %s is full", iid)
+			}
+		}
+
+		if len(iidsToDel) != 0 {
+			if errDb := tx.Model(&pkg.Interview{}).
+				Where("uid IN ?", iidsToDel).
+				Where("\"selectNumber\" > 0").
+				Updates(map[string]interface{}{
+					"\"selectNumber\"": gorm.Expr("\"selectNumber\" - ?", 1),
+				}).Error; errDb != nil {
+				return errDb
+			}
+		}
+		return recordEvent(tx, app.Uid, app.CandidateID, ActionSelectInterviews, app.Step, app.Step, "")
 	})
 	return err
 }
@@ -1,6 +1,11 @@
 package models
 
 import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
 	"UniqueRecruitmentBackend/global"
 	"UniqueRecruitmentBackend/pkg"
 )
@@ -13,11 +18,78 @@ func CreateComment(opts *pkg.CreateCommentOpts) (*pkg.Comment, error) {
 		MemberID:      opts.MemberID,
 		Content:       opts.Content,
 		Evaluation:    opts.Evaluation,
+		Scores:        opts.Scores,
+		Score:         opts.Score,
+		ParentID:      opts.ParentID,
+		Step:          opts.Step,
+		Visibility:    opts.Visibility,
 	}
 	err := db.Create(c).Error
 	return c, err
 }
 
+// UpdateComment replace the fields of comment given by opts, the comment before editing is kept as a revision
+func UpdateComment(cid string, opts *pkg.UpdateCommentOpts, editorID string) (*pkg.Comment, error) {
+	db := global.GetDB()
+	var c pkg.Comment
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid = ?", cid).
+			First(&c).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&pkg.CommentRevision{
+			CommentID:  c.Uid,
+			EditorID:   editorID,
+			Content:    c.Content,
+			Evaluation: c.Evaluation,
+			Scores:     c.Scores,
+			Score:      c.Score,
+			Visibility: c.Visibility,
+		}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if opts.Content != "" {
+			c.Content = opts.Content
+		}
+		if opts.Evaluation != 0 {
+			c.Evaluation = opts.Evaluation
+		}
+		// the score is computed from the scores, they are replaced together
+		if len(opts.Scores) != 0 {
+			c.Scores = opts.Scores
+			c.Score = opts.Score
+		}
+		c.EditedAt = &now
+		if opts.Visibility != "" {
+			c.Visibility = opts.Visibility
+		}
+		return tx.Model(&c).
+			Select("Content", "Evaluation", "Scores", "Score", "Visibility", "EditedAt").
+			Updates(&c).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// GetCommentRevisions get the edit history of comment, the latest first
+func GetCommentRevisions(cid string) ([]pkg.CommentRevision, error) {
+	db := global.GetDB()
+	var revisions []pkg.CommentRevision
+	if err := db.Model(&pkg.CommentRevision{}).
+		Where("\"commentId\" = ?", cid).
+		Order("\"createdAt\" DESC").
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
 func DeleteCommentById(cid string) error {
 	db := global.GetDB()
 	return db.Delete(&pkg.Comment{}, "uid = ?", cid).Error
@@ -33,3 +105,24 @@ func GetCommentById(cid string) (*pkg.Comment, error) {
 	}
 	return &c, nil
 }
+
+// visibleCommentSQL is the condition of comments the viewer can see, the same as pkg.Comment.VisibleTo
+// and pkg.FilterBlindComments: comments are hidden by their visibility, and others' comments at a blind step
+// are hidden until the step is revealed or the viewer has commented at it.
+// applications must be in the query, the named arguments are given by commentViewerArgs
+const visibleCommentSQL = `(@viewerIsAdmin OR comments."memberId" = @viewerUID OR (
+	comments.visibility <> 'admins' AND (comments.visibility <> 'group' OR applications."group" IN @viewerGroups)
+	AND NOT (
+		EXISTS (SELECT 1 FROM recruitments WHERE recruitments.uid = applications."recruitmentId" AND COALESCE(recruitments."blindSteps", '[]'::jsonb) @> to_jsonb(comments.step::text))
+		AND NOT EXISTS (SELECT 1 FROM evaluation_rounds WHERE evaluation_rounds."applicationId" = comments."applicationId" AND evaluation_rounds.step = comments.step AND evaluation_rounds."revealedAt" IS NOT NULL)
+		AND NOT EXISTS (SELECT 1 FROM comments mine WHERE mine."applicationId" = comments."applicationId" AND mine.step = comments.step AND mine."memberId" = @viewerUID)
+	)
+))`
+
+func commentViewerArgs(viewer *pkg.CommentViewer) map[string]interface{} {
+	return map[string]interface{}{
+		"viewerIsAdmin": viewer.IsAdmin,
+		"viewerUID":     viewer.UID,
+		"viewerGroups":  viewer.Groups,
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+const ActionRevealComments Action = "revealComments"
+
+var ErrCommentsRevealed = errors.New("the comments of this step have already been revealed")
+
+// UpdateRecruitmentBlindSteps set the steps evaluated blind
+func UpdateRecruitmentBlindSteps(rid string, steps []pkg.Step) error {
+	db := global.GetDB()
+	return db.Model(&pkg.Recruitment{}).
+		Where("uid = ?", rid).
+		Select("BlindSteps").
+		Updates(&pkg.Recruitment{BlindSteps: steps}).Error
+}
+
+// SetEvaluators assign the members to evaluate application at the step
+func SetEvaluators(aid string, step pkg.Step, evaluators []string) (*pkg.EvaluationRound, error) {
+	db := global.GetDB()
+	round := &pkg.EvaluationRound{ApplicationID: aid, Step: step, Evaluators: evaluators}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var revealed int64
+		if err := tx.Model(&pkg.EvaluationRound{}).
+			Where("\"applicationId\" = ? AND step = ? AND \"revealedAt\" IS NOT NULL", aid, step).
+			Count(&revealed).Error; err != nil {
+			return err
+		}
+		if revealed != 0 {
+			return ErrCommentsRevealed
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "applicationId"}, {Name: "step"}},
+			DoUpdates: clause.AssignmentColumns([]string{"evaluators", "updatedAt"}),
+		}).Create(round).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return round, nil
+}
+
+// GetEvaluationRounds get the rounds of application with the evaluators who have commented
+func GetEvaluationRounds(aid string) ([]pkg.EvaluationRound, error) {
+	db := global.GetDB()
+	var rounds []pkg.EvaluationRound
+	if err := db.Model(&pkg.EvaluationRound{}).
+		Where("\"applicationId\" = ?", aid).
+		Order("\"createdAt\"").
+		Find(&rounds).Error; err != nil {
+		return nil, err
+	}
+	for i := range rounds {
+		submitted, err := getSubmittedEvaluators(db, &rounds[i])
+		if err != nil {
+			return nil, err
+		}
+		rounds[i].Submitted = submitted
+	}
+	return rounds, nil
+}
+
+// GetRevealedSteps get the revealed steps of applications, keyed by application id
+func GetRevealedSteps(aids []string) (map[string]map[pkg.Step]bool, error) {
+	revealed := make(map[string]map[pkg.Step]bool)
+	if len(aids) == 0 {
+		return revealed, nil
+	}
+	db := global.GetDB()
+	var rounds []pkg.EvaluationRound
+	if err := db.Model(&pkg.EvaluationRound{}).
+		Select("\"applicationId\", step").
+		Where("\"applicationId\" IN ? AND \"revealedAt\" IS NOT NULL", aids).
+		Find(&rounds).Error; err != nil {
+		return nil, err
+	}
+	for _, round := range rounds {
+		if revealed[round.ApplicationID] == nil {
+			revealed[round.ApplicationID] = make(map[pkg.Step]bool)
+		}
+		revealed[round.ApplicationID][round.Step] = true
+	}
+	return revealed, nil
+}
+
+// RevealComments reveal the comments of application at the step to everyone,
+// it fails unless every assigned evaluator has commented at the step.
+// If evaluators are not assigned, the interviewers of the interview allocated to application are taken.
+func RevealComments(aid string, step pkg.Step, operator *pkg.Operator) error {
+	db := global.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		var app pkg.Application
+		if err := tx.Where("uid = ?", aid).First(&app).Error; err != nil {
+			return err
+		}
+
+		var rounds []pkg.EvaluationRound
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("\"applicationId\" = ? AND step = ?", aid, step).
+			Limit(1).
+			Find(&rounds).Error; err != nil {
+			return err
+		}
+		if len(rounds) == 0 {
+			interviewers, err := GetInterviewersOfApplication(&app, step)
+			if err != nil {
+				return err
+			}
+			if len(interviewers) == 0 {
+				return fmt.Errorf("no interviewers are assigned to evaluate the application at %s", step)
+			}
+			rounds = append(rounds, pkg.EvaluationRound{ApplicationID: aid, Step: step, Evaluators: interviewers})
+			if err = tx.Create(&rounds[0]).Error; err != nil {
+				return err
+			}
+		}
+		round := rounds[0]
+		if round.RevealedAt != nil {
+			return ErrCommentsRevealed
+		}
+
+		submitted, err := getSubmittedEvaluators(tx, &round)
+		if err != nil {
+			return err
+		}
+		if len(submitted) != len(round.Evaluators) {
+			return fmt.Errorf("%d of %d interviewers have submitted, comments can't be revealed", len(submitted), len(round.Evaluators))
+		}
+
+		if err = tx.Model(&pkg.EvaluationRound{}).
+			Where("uid = ?", round.Uid).
+			Updates(map[string]interface{}{
+				"\"revealedAt\"": time.Now(),
+				"\"revealerId\"": operator.UID,
+			}).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, aid, operator.UID, ActionRevealComments, app.Step, app.Step, string(step))
+	})
+}
+
+func getSubmittedEvaluators(db *gorm.DB, round *pkg.EvaluationRound) ([]string, error) {
+	submitted := make([]string, 0, len(round.Evaluators))
+	if len(round.Evaluators) == 0 {
+		return submitted, nil
+	}
+	err := db.Model(&pkg.Comment{}).
+		Distinct("\"memberId\"").
+		Where("\"applicationId\" = ? AND step = ? AND \"memberId\" IN ?", round.ApplicationID, round.Step, round.Evaluators).
+		Pluck("\"memberId\"", &submitted).Error
+	return submitted, err
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"gorm.io/gorm"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+// Action is the type of events, the transitions of step are in pkg
+type Action = pkg.Action
+
+const (
+	ActionCreate           Action = "create"
+	ActionUpdate           Action = "update"
+	ActionDelete           Action = "delete"
+	ActionSetInterviewTime Action = "setInterviewTime"
+	ActionSelectInterviews Action = "selectInterviews"
+)
+
+// recordEvent save the event in the transaction of the mutation
+func recordEvent(tx *gorm.DB, aid string, operatorID string, action Action, from, to pkg.Step, reason string) error {
+	return tx.Create(&pkg.ApplicationEvent{
+		ApplicationID: aid,
+		OperatorID:    operatorID,
+		Action:        string(action),
+		From:          from,
+		To:            to,
+		Reason:        reason,
+	}).Error
+}
+
+// GetApplicationEvents get the history of application, the earliest first
+func GetApplicationEvents(aid string) ([]pkg.ApplicationEvent, error) {
+	db := global.GetDB()
+	var events []pkg.ApplicationEvent
+	if err := db.Model(&pkg.ApplicationEvent{}).
+		Where("\"applicationId\" = ?", aid).
+		Order("\"createdAt\"").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
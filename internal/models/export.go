@@ -0,0 +1,64 @@
+package models
+
+import (
+	"gorm.io/gorm"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+const exportBatchSize = 200
+
+// EvaluationCount is the number of comments of each evaluation, keyed by application id
+type EvaluationCount map[string]map[pkg.Evaluation]int
+
+// ExportApplications walks through the applications of the groups in the recruitment batch by batch,
+// so that the whole recruitment is never loaded into memory. Interview allocations and the average
+// evaluation are loaded, comments and interview selections are not. Only the comments the viewer can see are counted.
+func ExportApplications(rid string, groups []string, viewer *pkg.CommentViewer, fn func(apps []pkg.Application, counts EvaluationCount) error) error {
+	db := global.GetDB()
+	var apps []pkg.Application
+	return db.Model(&pkg.Application{}).
+		Select("applications.*, "+averageEvaluationSQL+" AS \"averageEvaluation\"", commentViewerArgs(viewer)).
+		Preload("InterviewAllocationsGroup").
+		Preload("InterviewAllocationsTeam").
+		Where("applications.\"recruitmentId\" = ? AND applications.\"group\" IN ?", rid, groups).
+		FindInBatches(&apps, exportBatchSize, func(tx *gorm.DB, batch int) error {
+			aids := make([]string, 0, len(apps))
+			for _, app := range apps {
+				aids = append(aids, app.Uid)
+			}
+			counts, err := getEvaluationCount(db, aids, viewer)
+			if err != nil {
+				return err
+			}
+			return fn(apps, counts)
+		}).Error
+}
+
+func getEvaluationCount(db *gorm.DB, aids []string, viewer *pkg.CommentViewer) (EvaluationCount, error) {
+	var rows []struct {
+		ApplicationID string `gorm:"column:applicationId"`
+		Evaluation    pkg.Evaluation
+		Count         int
+	}
+	args := commentViewerArgs(viewer)
+	args["aids"] = aids
+	if err := db.Model(&pkg.Comment{}).
+		Select("comments.\"applicationId\", comments.evaluation, COUNT(*) AS count").
+		Joins("JOIN applications ON applications.uid = comments.\"applicationId\"").
+		Where("comments.\"applicationId\" IN @aids AND "+visibleCommentSQL, args).
+		Group("comments.\"applicationId\", comments.evaluation").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(EvaluationCount, len(aids))
+	for _, row := range rows {
+		if counts[row.ApplicationID] == nil {
+			counts[row.ApplicationID] = make(map[pkg.Evaluation]int)
+		}
+		counts[row.ApplicationID][row.Evaluation] = row.Count
+	}
+	return counts, nil
+}
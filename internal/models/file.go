@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"mime/multipart"
+
+	"github.com/xylonx/zapx"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/storage"
+)
+
+const referencedFilesBatchSize = 1000
+
+// stageFile uploads the file to a staging key before the transaction,
+// so that a slow upload doesn't hold the transaction
+func stageFile(key string, file *multipart.FileHeader) (string, error) {
+	return storage.StageFile(context.Background(), global.GetStorage(), key, file)
+}
+
+// promoteFiles moves the staged files to their keys after the transaction is committed.
+// Failure is only logged, the storage sweeper promotes them later
+func promoteFiles(stagingKeys []string) {
+	for _, stagingKey := range stagingKeys {
+		if err := storage.Promote(context.Background(), global.GetStorage(), stagingKey); err != nil {
+			zapx.Warn("promote staged file failed", zap.String("key", stagingKey), zap.Error(err))
+		}
+	}
+}
+
+// discardFiles deletes the staged files after the transaction fails
+func discardFiles(stagingKeys []string) {
+	for _, stagingKey := range stagingKeys {
+		if err := global.GetStorage().Delete(context.Background(), stagingKey); err != nil {
+			zapx.Warn("delete staged file failed", zap.String("key", stagingKey), zap.Error(err))
+		}
+	}
+}
+
+// GetReferencedFiles returns which of the keys are the resume or answer of applications,
+// the old versions of resumes are referenced as well
+func GetReferencedFiles(keys []string) (map[string]bool, error) {
+	db := global.GetDB()
+	referenced := make(map[string]bool)
+	for start := 0; start < len(keys); start += referencedFilesBatchSize {
+		end := start + referencedFilesBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+
+		var apps []pkg.Application
+		if err := db.Model(&pkg.Application{}).
+			Select("resume", "answer").
+			Where("resume IN ? OR answer IN ?", batch, batch).
+			Find(&apps).Error; err != nil {
+			return nil, err
+		}
+		for _, app := range apps {
+			referenced[app.Resume] = true
+			referenced[app.Answer] = true
+		}
+
+		var versionKeys []string
+		if err := db.Model(&pkg.ResumeVersion{}).
+			Where("key IN ?", batch).
+			Pluck("key", &versionKeys).Error; err != nil {
+			return nil, err
+		}
+		for _, key := range versionKeys {
+			referenced[key] = true
+		}
+	}
+	return referenced, nil
+}
+
+// AttachUploadedFile sets the file uploaded directly to the staging key as the resume or answer of application,
+// the staged file is promoted after the application is saved. resume describes the file if it's a resume,
+// which is saved as a new version
+func AttachUploadedFile(a *pkg.Application, uploadType pkg.UploadType, stagingKey string, resume *pkg.ResumeVersion) error {
+	key, ok := storage.PromotedKey(stagingKey)
+	if !ok {
+		return storage.ErrInvalidKey
+	}
+	column := "resume"
+	if uploadType == pkg.UploadAnswer {
+		column = "answer"
+	}
+
+	db := global.GetDB()
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if errdb := tx.Model(&pkg.Application{}).Where("uid = ?", a.Uid).Update(column, key).Error; errdb != nil {
+			return errdb
+		}
+		if uploadType == pkg.UploadResume {
+			resume.Key = key
+			if errdb := createResumeVersion(tx, a.Uid, resume); errdb != nil {
+				return errdb
+			}
+		}
+		return recordEvent(tx, a.Uid, a.CandidateID, ActionUpdate, a.Step, a.Step, "")
+	}); err != nil {
+		return err
+	}
+	promoteFiles([]string{stagingKey})
+
+	if uploadType == pkg.UploadAnswer {
+		a.Answer = key
+	} else {
+		a.Resume = key
+	}
+	return nil
+}
@@ -3,7 +3,12 @@ package models
 import (
 	"UniqueRecruitmentBackend/global"
 	"UniqueRecruitmentBackend/pkg"
+	"errors"
 	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func GetInterviewById(iid string) (*pkg.Interview, error) {
@@ -21,12 +26,14 @@ func GetInterviewsByRidAndNameWithoutApp(rid string, name pkg.Group) ([]pkg.Inte
 	db := global.GetDB()
 	var res []pkg.Interview
 	if err := db.Model(&pkg.Interview{}).
-		//Omit("\"selectNumber\", \"slotNumber\"").
-		//Where("\"selectNumber\" < \"slotNumber\"").
 		Where("\"recruitmentId\" = ? AND name = ?", rid, name).
+		Order("start").
 		Find(&res).Error; err != nil {
 		return nil, err
 	}
+	for i := range res {
+		res[i].SetRemaining()
+	}
 	return res, nil
 }
 
@@ -69,11 +76,13 @@ func UpdateInterview(interview *pkg.Interview) error {
 	if err := db.Model(&pkg.Interview{}).
 		Where("\"uid\" = ?", interview.Uid).
 		Updates(map[string]interface{}{
-			"date":   interview.Date,
-			"period": interview.Period,
-			"start":  interview.Start,
-			"end":    interview.End,
-			"name":   interview.Name,
+			"date":                  interview.Date,
+			"period":                interview.Period,
+			"start":                 interview.Start,
+			"end":                   interview.End,
+			"name":                  interview.Name,
+			"\"slotNumber\"":        interview.SlotNumber,
+			"\"interviewerNumber\"": interview.InterviewerNumber,
 		}).Error; err != nil {
 		return err
 	}
@@ -88,13 +97,111 @@ func AddAndDeleteInterviews(interviewsToAdd []pkg.Interview, interviewIdsToDel [
 		}
 	}
 	if len(interviewIdsToDel) != 0 {
-		if errDelete := db.Delete(&pkg.Interview{}, "uid in ?", interviewIdsToDel).Error; errDelete != nil {
+		var interviews []pkg.Interview
+		if errDb := db.Where("uid in ?", interviewIdsToDel).Find(&interviews).Error; errDb != nil {
+			return errDb
+		}
+		if errDelete := db.Transaction(func(tx *gorm.DB) error {
+			return deleteInterviewsTx(tx, interviews)
+		}); errDelete != nil {
 			return errDelete
 		}
 	}
 	return
 }
 
+// deleteInterviewsTx delete interviews and keep their cancellations for calendar feeds
+func deleteInterviewsTx(tx *gorm.DB, interviews []pkg.Interview) error {
+	if len(interviews) == 0 {
+		return nil
+	}
+
+	cancellations := make([]pkg.InterviewCancellation, 0, len(interviews))
+	iids := make([]string, 0, len(interviews))
+	for _, interview := range interviews {
+		cancellations = append(cancellations, pkg.InterviewCancellation{
+			InterviewID:   interview.Uid,
+			RecruitmentID: interview.RecruitmentID,
+			Name:          interview.Name,
+			Start:         interview.Start,
+			End:           interview.End,
+		})
+		iids = append(iids, interview.Uid)
+	}
+	if err := tx.Create(&cancellations).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&pkg.Interview{}, "uid in ?", iids).Error
+}
+
+// GetInterviewCancellations get the cancelled interviews of recruitment named name
+func GetInterviewCancellations(rid string, name pkg.Group) ([]pkg.InterviewCancellation, error) {
+	db := global.GetDB()
+	var cancellations []pkg.InterviewCancellation
+	if err := db.Model(&pkg.InterviewCancellation{}).
+		Where("\"recruitmentId\" = ? AND name = ?", rid, name).
+		Order("start").
+		Find(&cancellations).Error; err != nil {
+		return nil, err
+	}
+	return cancellations, nil
+}
+
+// GetAllocationCancellation get the cancellation of the interview the application was allocated to of the type,
+// when the allocation has been cleared or the interview has been deleted. It's found by the events of application,
+// CreatedAt is when the allocation or the interview is cancelled. nil if the application has never been allocated
+func GetAllocationCancellation(aid string, interviewType pkg.GroupOrTeam) (*pkg.InterviewCancellation, error) {
+	db := global.GetDB()
+	prefix := interviewEventReason(interviewType, "")
+	var events []pkg.ApplicationEvent
+	if err := db.Model(&pkg.ApplicationEvent{}).
+		Where("\"applicationId\" = ? AND action = ? AND reason LIKE ?", aid, ActionSetInterviewTime, prefix+"%").
+		Order("\"createdAt\" DESC").
+		Find(&events).Error; err != nil {
+		return nil, err
+	}
+	var iid string
+	for _, event := range events {
+		if iid = strings.TrimPrefix(event.Reason, prefix); iid != "" {
+			break
+		}
+	}
+	if iid == "" {
+		return nil, nil
+	}
+	// the last event is the clearing of allocation, if the allocation is not cleared it's the allocation itself
+	changedAt := events[0].CreatedAt
+
+	var cancellation pkg.InterviewCancellation
+	err := db.Where("\"interviewId\" = ?", iid).First(&cancellation).Error
+	switch {
+	case err == nil:
+		if changedAt.After(cancellation.CreatedAt) {
+			cancellation.CreatedAt = changedAt
+		}
+		return &cancellation, nil
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, err
+	}
+
+	// the interview is kept, only the allocation is cleared
+	interview, err := GetInterviewById(iid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pkg.InterviewCancellation{
+		Common:        pkg.Common{CreatedAt: changedAt},
+		InterviewID:   interview.Uid,
+		RecruitmentID: interview.RecruitmentID,
+		Name:          interview.Name,
+		Start:         interview.Start,
+		End:           interview.End,
+	}, nil
+}
+
 func GetInterviewsCannotBeUpdate(iids []string) (map[string]struct{}, error) {
 	db := global.GetDB()
 	interviewsCannotBeUpdate := make(map[string]struct{})
@@ -143,12 +250,14 @@ func CreateInterviews(opts []pkg.CreateInterviewOpts, name pkg.Group, rid string
 	var errs []error
 	for _, opt := range opts {
 		dbErr := db.Model(&pkg.Interview{}).Create(&pkg.Interview{
-			RecruitmentID: rid,
-			Name:          name,
-			Date:          opt.Date,
-			Period:        opt.Period,
-			Start:         opt.Start,
-			End:           opt.End,
+			RecruitmentID:     rid,
+			Name:              name,
+			Date:              opt.Date,
+			Period:            opt.Period,
+			Start:             opt.Start,
+			End:               opt.End,
+			SlotNumber:        opt.SlotNumber,
+			InterviewerNumber: opt.InterviewerNumber,
 		}).Error
 		if dbErr != nil {
 			errs = append(errs, dbErr)
@@ -192,9 +301,17 @@ func DeleteInterviews(opts []pkg.DeleteInterviewOpts, name pkg.Group, rid string
 			continue
 		}
 
+		var interviews []pkg.Interview
 		dbErr = db.Model(&pkg.Interview{}).
 			Where("\"recruitmentId\" = ? AND name = ? AND uid = ?", rid, name, opt.Iid).
-			Delete(&pkg.Interview{}).Error
+			Find(&interviews).Error
+		if dbErr != nil {
+			errs = append(errs, dbErr)
+			continue
+		}
+		dbErr = db.Transaction(func(tx *gorm.DB) error {
+			return deleteInterviewsTx(tx, interviews)
+		})
 		if dbErr != nil {
 			errs = append(errs, dbErr)
 		}
@@ -205,3 +322,190 @@ func DeleteInterviews(opts []pkg.DeleteInterviewOpts, name pkg.Group, rid string
 	}
 	return
 }
+
+// getApplicationsToAllocate get applications waiting for the group/team interview allocation,
+// only the interviews named name are preloaded in the interview selections
+func getApplicationsToAllocate(tx *gorm.DB, rid string, name pkg.Group, interviewType pkg.GroupOrTeam) ([]pkg.Application, error) {
+	var apps []pkg.Application
+	query := tx.Model(&pkg.Application{}).
+		Preload("InterviewSelections", "name = ?", name).
+		Where("\"recruitmentId\" = ? AND rejected = ? AND abandoned = ?", rid, false, false)
+	if interviewType == pkg.InGroup {
+		query = query.Where("step = ? AND \"group\" = ?", pkg.GroupTimeSelection, name)
+	} else {
+		query = query.Where("step = ?", pkg.TeamTimeSelection)
+	}
+	if err := query.Order("\"createdAt\"").Find(&apps).Error; err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
+
+// CountInterviewAllocations count the applications allocated to each interview,
+// applications in excludedAids are not counted
+func CountInterviewAllocations(iids []string, interviewType pkg.GroupOrTeam, excludedAids []string) (map[string]int, error) {
+	return countInterviewAllocations(global.GetDB(), iids, interviewType, excludedAids)
+}
+
+func countInterviewAllocations(tx *gorm.DB, iids []string, interviewType pkg.GroupOrTeam, excludedAids []string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(iids) == 0 {
+		return counts, nil
+	}
+
+	column := allocationColumn(interviewType)
+	var results []struct {
+		Iid   string
+		Count int
+	}
+	query := tx.Model(&pkg.Application{}).
+		Select(column+" AS iid, count(*) AS count").
+		Where(column+" IN ?", iids)
+	if len(excludedAids) != 0 {
+		query = query.Where("uid NOT IN ?", excludedAids)
+	}
+	if err := query.Group(column).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	for _, result := range results {
+		counts[result.Iid] = result.Count
+	}
+	return counts, nil
+}
+
+// AllocateInterviews allocate the applications waiting for the group/team interviews named opts.Name in one transaction.
+// The interviews are locked before the seats are counted and the applications are read, so concurrent allocations
+// and manual settings of interview time can't overbook them. An application changed by others in the meantime is left unallocated.
+func AllocateInterviews(opts *pkg.AllocateInterviewsOpts, operator *pkg.Operator) (*pkg.AllocateInterviewsResp, error) {
+	db := global.GetDB()
+	interviewType := opts.InterviewType()
+	column := allocationColumn(interviewType)
+	// applications to allocate are all at the time selection step
+	step := pkg.GroupTimeSelection
+	if interviewType == pkg.InTeam {
+		step = pkg.TeamTimeSelection
+	}
+
+	var resp *pkg.AllocateInterviewsResp
+	err := db.Transaction(func(tx *gorm.DB) error {
+		resp = &pkg.AllocateInterviewsResp{
+			Allocations: []pkg.InterviewAllocation{},
+			Unallocated: []pkg.UnallocatedApplication{},
+		}
+
+		// lock rows in the same order to avoid deadlock
+		var interviews []pkg.Interview
+		if errDb := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("\"recruitmentId\" = ? AND name = ?", opts.Rid, opts.Name).
+			Order("uid").
+			Find(&interviews).Error; errDb != nil {
+			return errDb
+		}
+		iids := make([]string, 0, len(interviews))
+		for _, interview := range interviews {
+			iids = append(iids, interview.Uid)
+		}
+
+		apps, errDb := getApplicationsToAllocate(tx, opts.Rid, opts.Name, interviewType)
+		if errDb != nil {
+			return errDb
+		}
+		var toAllocate []pkg.Application
+		var aids []string
+		previous := make(map[string]string) // aid -> iid allocated before
+		for _, app := range apps {
+			allocated := app.InterviewAllocationsGroupId
+			if interviewType == pkg.InTeam {
+				allocated = app.InterviewAllocationsTeamId
+			}
+			if allocated != "" && !opts.Reallocate {
+				continue
+			}
+			toAllocate = append(toAllocate, app)
+			aids = append(aids, app.Uid)
+			previous[app.Uid] = allocated
+		}
+
+		// the seats taken by candidates who are not reallocated
+		counts, errDb := countInterviewAllocations(tx, iids, interviewType, aids)
+		if errDb != nil {
+			return errDb
+		}
+		capacity := make(map[string]int)
+		for _, interview := range interviews {
+			slotNumber := interview.SlotNumber
+			if slotNumber <= 0 {
+				slotNumber = opts.Capacity
+			}
+			if slotNumber <= 0 {
+				// neither the interview nor the request limits it
+				slotNumber = len(toAllocate) + counts[interview.Uid]
+			}
+			capacity[interview.Uid] = slotNumber - counts[interview.Uid]
+		}
+
+		allocated, _ := pkg.AllocateInterviews(toAllocate, capacity)
+		for _, app := range toAllocate {
+			iid := allocated[app.Uid]
+			reason := ""
+			if iid == "" {
+				reason = "all selected interviews are full"
+				if len(app.InterviewSelections) == 0 {
+					reason = "no interview selected"
+				}
+			}
+
+			if iid != previous[app.Uid] {
+				// the allocation is cleared if the application can't be reallocated,
+				// the application must be unchanged since it's read
+				var value interface{}
+				if iid != "" {
+					value = iid
+				}
+				query := tx.Model(&pkg.Application{}).
+					Where("uid = ? AND step = ? AND rejected = ? AND abandoned = ?", app.Uid, step, false, false)
+				if previous[app.Uid] == "" {
+					query = query.Where(column + " IS NULL")
+				} else {
+					query = query.Where(column+" = ?", previous[app.Uid])
+				}
+				result := query.Update(column, value)
+				if result.Error != nil {
+					return result.Error
+				}
+				if result.RowsAffected == 0 {
+					iid, reason = "", "the application is changed during allocation"
+				} else if errDb := recordEvent(tx, app.Uid, operator.UID, ActionSetInterviewTime, step, step,
+					interviewEventReason(interviewType, iid)); errDb != nil {
+					return errDb
+				}
+			}
+
+			if iid == "" {
+				resp.Unallocated = append(resp.Unallocated, pkg.UnallocatedApplication{
+					Aid:         app.Uid,
+					CandidateID: app.CandidateID,
+					Reason:      reason,
+				})
+				continue
+			}
+			resp.Allocations = append(resp.Allocations, pkg.InterviewAllocation{
+				Aid:         app.Uid,
+				CandidateID: app.CandidateID,
+				Iid:         iid,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func allocationColumn(interviewType pkg.GroupOrTeam) string {
+	if interviewType == pkg.InTeam {
+		return "\"interviewAllocationsTeamId\""
+	}
+	return "\"interviewAllocationsGroupId\""
+}
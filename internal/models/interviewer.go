@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+var ErrAlreadyInterviewer = errors.New("you have already signed up for the interview")
+
+// AddInterviewer sign up the member to run the interview, the interview row is locked
+// so that concurrent sign ups can't exceed the interviewer number
+func AddInterviewer(iid string, member *pkg.UserDetail) (*pkg.Interviewer, error) {
+	db := global.GetDB()
+	interviewer := &pkg.Interviewer{InterviewID: iid, MemberID: member.UID, MemberName: member.Name}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var interview pkg.Interview
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid = ?", iid).
+			First(&interview).Error; err != nil {
+			return err
+		}
+
+		var uids []string
+		if err := tx.Model(&pkg.Interviewer{}).
+			Where("\"interviewId\" = ?", iid).
+			Pluck("\"memberId\"", &uids).Error; err != nil {
+			return err
+		}
+		for _, uid := range uids {
+			if uid == member.UID {
+				return ErrAlreadyInterviewer
+			}
+		}
+		if interview.InterviewerNumber > 0 && len(uids) >= interview.InterviewerNumber {
+			return fmt.Errorf("the interview already has %d interviewers", interview.InterviewerNumber)
+		}
+		return tx.Create(interviewer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return interviewer, nil
+}
+
+func RemoveInterviewer(iid string, uid string) error {
+	db := global.GetDB()
+	result := db.Where("\"interviewId\" = ? AND \"memberId\" = ?", iid, uid).Delete(&pkg.Interviewer{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("you haven't signed up for the interview")
+	}
+	return nil
+}
+
+// GetInterviewPanels get the interviews of group (or unique) with their interviewers and the number of allocated candidates
+func GetInterviewPanels(rid string, name pkg.Group) ([]pkg.Interview, error) {
+	db := global.GetDB()
+	var interviews []pkg.Interview
+	if err := db.Model(&pkg.Interview{}).
+		Preload("Interviewers", func(db *gorm.DB) *gorm.DB {
+			return db.Order("\"createdAt\"")
+		}).
+		Where("\"recruitmentId\" = ? AND name = ?", rid, name).
+		Order("start").
+		Find(&interviews).Error; err != nil {
+		return nil, err
+	}
+
+	iids := make([]string, 0, len(interviews))
+	for _, interview := range interviews {
+		iids = append(iids, interview.Uid)
+	}
+	counts, err := CountInterviewAllocations(iids, interviewTypeOf(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range interviews {
+		interviews[i].SetStaffing(counts[interviews[i].Uid])
+		interviews[i].SetRemaining()
+	}
+	return interviews, nil
+}
+
+// GetMemberInterviews get the interviews the member runs which start in [start, end),
+// with the candidates allocated to them. Comments of the candidates are loaded.
+func GetMemberInterviews(uid string, start, end time.Time) ([]pkg.MyInterview, error) {
+	db := global.GetDB()
+	var interviews []pkg.Interview
+	if err := db.Model(&pkg.Interview{}).
+		Preload("Interviewers").
+		Where("uid IN (?)", db.Model(&pkg.Interviewer{}).Select("\"interviewId\"").Where("\"memberId\" = ?", uid)).
+		Where("start >= ? AND start < ?", start, end).
+		Order("start").
+		Find(&interviews).Error; err != nil {
+		return nil, err
+	}
+
+	mine := make([]pkg.MyInterview, 0, len(interviews))
+	for _, interview := range interviews {
+		var apps []pkg.Application
+		if err := db.Model(&pkg.Application{}).
+			Preload("Comments").
+			Preload("InterviewAllocationsGroup").
+			Preload("InterviewAllocationsTeam").
+			Where(allocationColumn(interviewTypeOf(interview.Name))+" = ?", interview.Uid).
+			Where("abandoned = ? AND rejected = ?", false, false).
+			Order("\"createdAt\"").
+			Find(&apps).Error; err != nil {
+			return nil, err
+		}
+		mine = append(mine, pkg.MyInterview{Interview: interview, Candidates: apps})
+	}
+	return mine, nil
+}
+
+// GetInterviewersOfApplication get the uid of members running the interview allocated to application at the step,
+// nil if the step is not an interview or the application isn't allocated
+func GetInterviewersOfApplication(app *pkg.Application, step pkg.Step) ([]string, error) {
+	var iid string
+	switch step {
+	case pkg.GroupInterview, pkg.OnlineGroupInterview:
+		iid = app.InterviewAllocationsGroupId
+	case pkg.TeamInterview, pkg.OnlineTeamInterview:
+		iid = app.InterviewAllocationsTeamId
+	}
+	if iid == "" {
+		return nil, nil
+	}
+
+	db := global.GetDB()
+	var uids []string
+	if err := db.Model(&pkg.Interviewer{}).
+		Where("\"interviewId\" = ?", iid).
+		Pluck("\"memberId\"", &uids).Error; err != nil {
+		return nil, err
+	}
+	return uids, nil
+}
+
+// interviewTypeOf get the type of interviews by their name, interviews of unique are team interviews
+func interviewTypeOf(name pkg.Group) pkg.GroupOrTeam {
+	if name == pkg.Unique {
+		return pkg.InTeam
+	}
+	return pkg.InGroup
+}
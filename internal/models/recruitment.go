@@ -3,9 +3,9 @@ package models
 import (
 	"UniqueRecruitmentBackend/global"
 	"UniqueRecruitmentBackend/pkg"
-	"UniqueRecruitmentBackend/pkg/grpc"
 	"encoding/json"
 	"errors"
+	"fmt"
 )
 
 func CreateRecruitment(opts *pkg.CreateRecOpts) (r *pkg.Recruitment, err error) {
@@ -21,6 +21,7 @@ func CreateRecruitment(opts *pkg.CreateRecOpts) (r *pkg.Recruitment, err error)
 		Beginning: opts.Beginning,
 		Deadline:  opts.Deadline,
 		End:       opts.End,
+		Steps:     opts.Steps,
 	}
 	err = db.Model(&pkg.Recruitment{}).Create(r).Error
 	return
@@ -68,15 +69,17 @@ func GetFullRecruitmentById(rid string) (*pkg.Recruitment, error) {
 		return nil, err
 	}
 
-	for i := range r.Applications {
-		r.Applications[i].UserDetail, err = grpc.GetUserInfoByUID(r.Applications[i].CandidateID)
-		if err != nil {
-			return nil, err
-		}
-	}
+	fillApplicationsScore(r.Applications)
 	return &r, err
 }
 
+// fillApplicationsScore set the average rubric score of the loaded comments
+func fillApplicationsScore(apps []pkg.Application) {
+	for i := range apps {
+		apps[i].Score = pkg.AverageScore(apps[i].Comments)
+	}
+}
+
 func GetAllRecruitment() ([]pkg.Recruitment, error) {
 	db := global.GetDB()
 	var r []pkg.Recruitment
@@ -135,3 +138,25 @@ func UpdateStressTestTime(opts *pkg.SetStressTestTimeOpts) error {
 	}
 	return nil
 }
+
+// UpdateRecruitmentSteps set the pipeline of recruitment,
+// it fails if any application stays at a step removed from the pipeline
+func UpdateRecruitmentSteps(opts *pkg.SetRecStepsOpts) error {
+	db := global.GetDB()
+	var steps []pkg.Step
+	if err := db.Model(&pkg.Application{}).
+		Distinct("step").
+		Where("\"recruitmentId\" = ?", opts.Rid).
+		Pluck("step", &steps).Error; err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if !opts.Steps.Contains(step) {
+			return fmt.Errorf("there are applications at step %s, which can't be removed", step)
+		}
+	}
+
+	return db.Model(&pkg.Recruitment{}).
+		Where("uid = ?", opts.Rid).
+		Update("steps", opts.Steps).Error
+}
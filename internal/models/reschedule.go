@@ -0,0 +1,152 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+const (
+	ActionRequestReschedule Action = "requestReschedule"
+	ActionDenyReschedule    Action = "denyReschedule"
+)
+
+var (
+	ErrRescheduleReviewed = errors.New("the reschedule request has already been reviewed")
+	ErrInterviewFull      = errors.New("the interview is full")
+)
+
+// CreateRescheduleRequest save the request of candidate, an application can only have
+// one pending request for each interview type. The application row is locked so that
+// concurrent requests can't both pass the check.
+func CreateRescheduleRequest(req *pkg.RescheduleRequest, operator *pkg.Operator) error {
+	db := global.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		var app pkg.Application
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid = ?", req.ApplicationID).
+			First(&app).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&pkg.RescheduleRequest{}).
+			Where("\"applicationId\" = ? AND \"interviewType\" = ? AND status = ?",
+				req.ApplicationID, req.InterviewType, pkg.ReschedulePending).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count != 0 {
+			return errors.New("there is already a pending reschedule request")
+		}
+
+		req.Status = pkg.ReschedulePending
+		if err := tx.Omit("Alternatives.*").Create(req).Error; err != nil {
+			return err
+		}
+		return recordEvent(tx, app.Uid, operator.UID, ActionRequestReschedule, app.Step, app.Step, req.Reason)
+	})
+}
+
+func GetRescheduleRequestById(id string) (*pkg.RescheduleRequest, error) {
+	db := global.GetDB()
+	var req pkg.RescheduleRequest
+	if err := db.Model(&pkg.RescheduleRequest{}).
+		Preload("Alternatives").
+		Where("uid = ?", id).
+		First(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// GetRescheduleRequestsByRid get the requests of applications in the groups, the earliest first
+func GetRescheduleRequestsByRid(rid string, groups []string, status pkg.RescheduleStatus) ([]pkg.RescheduleRequest, error) {
+	db := global.GetDB()
+	var reqs []pkg.RescheduleRequest
+	if err := db.Model(&pkg.RescheduleRequest{}).
+		Preload("Alternatives", func(db *gorm.DB) *gorm.DB {
+			return db.Order("start")
+		}).
+		Joins("JOIN applications ON applications.uid = reschedule_requests.\"applicationId\"").
+		Where("applications.\"recruitmentId\" = ? AND applications.\"group\" IN ? AND reschedule_requests.status = ?", rid, groups, status).
+		Order("reschedule_requests.\"createdAt\"").
+		Find(&reqs).Error; err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// ApproveRescheduleRequest move the application to the chosen interview if it has a seat left,
+// in the same transaction as marking the request approved
+func ApproveRescheduleRequest(req *pkg.RescheduleRequest, app *pkg.Application, iid string, note string, operator *pkg.Operator) error {
+	db := global.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := reviewRescheduleRequest(tx, req.Uid, map[string]interface{}{
+			"status":                  pkg.RescheduleApproved,
+			"\"reviewerId\"":          operator.UID,
+			"\"reviewNote\"":          note,
+			"\"approvedInterviewId\"": iid,
+		}); err != nil {
+			return err
+		}
+		if err := checkInterviewSeatTx(tx, iid, req.InterviewType, app.Uid); err != nil {
+			return err
+		}
+		return setApplicationInterviewTimeTx(tx, app, req.InterviewType, iid, operator)
+	})
+}
+
+func DenyRescheduleRequest(req *pkg.RescheduleRequest, app *pkg.Application, note string, operator *pkg.Operator) error {
+	db := global.GetDB()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := reviewRescheduleRequest(tx, req.Uid, map[string]interface{}{
+			"status":         pkg.RescheduleDenied,
+			"\"reviewerId\"": operator.UID,
+			"\"reviewNote\"": note,
+		}); err != nil {
+			return err
+		}
+		return recordEvent(tx, app.Uid, operator.UID, ActionDenyReschedule, app.Step, app.Step, note)
+	})
+}
+
+// reviewRescheduleRequest only updates pending request, so a request can't be reviewed twice
+func reviewRescheduleRequest(tx *gorm.DB, id string, updates map[string]interface{}) error {
+	result := tx.Model(&pkg.RescheduleRequest{}).
+		Where("uid = ? AND status = ?", id, pkg.ReschedulePending).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRescheduleReviewed
+	}
+	return nil
+}
+
+// checkInterviewSeatTx check the interview has a seat left for the application, the interview row is locked
+// so that the seat can't be taken by others before the application is set to it
+func checkInterviewSeatTx(tx *gorm.DB, iid string, interviewType pkg.GroupOrTeam, aid string) error {
+	var interview pkg.Interview
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("uid = ?", iid).
+		First(&interview).Error; err != nil {
+		return err
+	}
+	if interview.SlotNumber <= 0 {
+		return nil
+	}
+	counts, err := countInterviewAllocations(tx, []string{iid}, interviewType, []string{aid})
+	if err != nil {
+		return err
+	}
+	if counts[iid] >= interview.SlotNumber {
+		return ErrInterviewFull
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+var ErrResumeVersionNotFound = errors.New("the version of resume doesn't exist")
+
+// createResumeVersion saves the resume as the next version of application. It must be called after
+// the application row is written in tx, whose lock keeps concurrent uploads from taking the same version.
+// The same file uploaded again doesn't make a new version
+func createResumeVersion(tx *gorm.DB, aid string, resume *pkg.ResumeVersion) error {
+	var latest pkg.ResumeVersion
+	err := tx.Where("\"applicationId\" = ?", aid).Order("version DESC").First(&latest).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+	case err != nil:
+		return err
+	case latest.Key == resume.Key:
+		*resume = latest
+		return nil
+	}
+
+	resume.ApplicationID = aid
+	resume.Version = latest.Version + 1
+	return tx.Create(resume).Error
+}
+
+// GetResumeVersions returns all resumes of application, the earliest first
+func GetResumeVersions(aid string) ([]pkg.ResumeVersion, error) {
+	db := global.GetDB()
+	var versions []pkg.ResumeVersion
+	if err := db.Where("\"applicationId\" = ?", aid).
+		Order("version").
+		Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func GetResumeVersion(aid string, version int) (*pkg.ResumeVersion, error) {
+	db := global.GetDB()
+	var v pkg.ResumeVersion
+	err := db.Where("\"applicationId\" = ? AND version = ?", aid, version).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrResumeVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
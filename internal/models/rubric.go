@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+var ErrRubricInUse = errors.New("applications have been scored by the rubric, it can't be changed")
+
+// SetRubric create or replace the rubric of group in recruitment.
+// Scores of comments are kept by criterion key, so the rubric can't be changed once it's used.
+func SetRubric(opts *pkg.SetRubricOpts) (*pkg.Rubric, error) {
+	db := global.GetDB()
+	rubric := &pkg.Rubric{
+		RecruitmentID: opts.Rid,
+		Group:         opts.Group,
+		Criteria:      opts.Criteria,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&pkg.Comment{}).
+			Joins("JOIN applications ON applications.uid = comments.\"applicationId\"").
+			Where("applications.\"recruitmentId\" = ? AND applications.\"group\" = ? AND comments.score IS NOT NULL", opts.Rid, opts.Group).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count != 0 {
+			return ErrRubricInUse
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "recruitmentId"}, {Name: "group"}},
+			DoUpdates: clause.AssignmentColumns([]string{"criteria", "updatedAt"}),
+		}).Create(rubric).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rubric, nil
+}
+
+// GetRubric get the rubric of group in recruitment, nil if the group doesn't have one
+func GetRubric(rid string, group pkg.Group) (*pkg.Rubric, error) {
+	db := global.GetDB()
+	var rubrics []pkg.Rubric
+	if err := db.Model(&pkg.Rubric{}).
+		Where("\"recruitmentId\" = ? AND \"group\" = ?", rid, group).
+		Limit(1).
+		Find(&rubrics).Error; err != nil {
+		return nil, err
+	}
+	if len(rubrics) == 0 {
+		return nil, nil
+	}
+	return &rubrics[0], nil
+}
+
+func GetRubricsByRid(rid string) ([]pkg.Rubric, error) {
+	db := global.GetDB()
+	var rubrics []pkg.Rubric
+	if err := db.Model(&pkg.Rubric{}).
+		Where("\"recruitmentId\" = ?", rid).
+		Order("\"group\"").
+		Find(&rubrics).Error; err != nil {
+		return nil, err
+	}
+	return rubrics, nil
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"strings"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+// weights of the fields matched by search, name and phone of candidate are matched by caller from sso
+const (
+	searchWeightUser      = 5
+	searchWeightInstitute = 3
+	searchWeightMajor     = 3
+	searchWeightReferrer  = 2
+	searchWeightIntro     = 1
+	searchWeightComment   = 1
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// GetCandidateIDsByRid get candidate id of applications in the groups, nil groups means all groups
+func GetCandidateIDsByRid(rid string, groups []string) (map[string]string, error) {
+	db := global.GetDB()
+	var apps []pkg.Application
+	query := db.Model(&pkg.Application{}).
+		Select("uid, \"candidateId\"").
+		Where("\"recruitmentId\" = ?", rid)
+	if groups != nil {
+		query = query.Where("\"group\" IN ?", groups)
+	}
+	if err := query.Find(&apps).Error; err != nil {
+		return nil, err
+	}
+
+	candidates := make(map[string]string) // aid -> candidate id
+	for _, app := range apps {
+		candidates[app.Uid] = app.CandidateID
+	}
+	return candidates, nil
+}
+
+// SearchApplications search applications in the groups (nil means all groups) by substring of intro, institute,
+// major, referrer and comments, userMatched are applications whose candidate's name or phone matches.
+// Results are ranked by the weights of matched fields, the trigram similarity of intro breaks ties.
+// Only the comments the viewer can see are matched. ILIKE is accelerated by the pg_trgm indexes created in migration.
+func SearchApplications(rid string, q string, groups []string, userMatched []string, limit int, viewer *pkg.CommentViewer) ([]pkg.Application, error) {
+	db := global.GetDB()
+	pattern := "%" + likeEscaper.Replace(q) + "%"
+	if len(userMatched) == 0 {
+		userMatched = []string{}
+	}
+
+	score := `(CASE WHEN COALESCE(applications.institute, '') ILIKE @pattern THEN @institute ELSE 0 END +
+		CASE WHEN COALESCE(applications.major, '') ILIKE @pattern THEN @major ELSE 0 END +
+		CASE WHEN COALESCE(applications.referrer, '') ILIKE @pattern THEN @referrer ELSE 0 END +
+		CASE WHEN COALESCE(applications.intro, '') ILIKE @pattern THEN @intro ELSE 0 END +
+		CASE WHEN EXISTS (SELECT 1 FROM comments WHERE comments."applicationId" = applications.uid AND comments.content ILIKE @pattern AND ` + visibleCommentSQL + `) THEN @comment ELSE 0 END +
+		CASE WHEN applications.uid::text IN @users THEN @user ELSE 0 END +
+		word_similarity(@q, COALESCE(applications.intro, '')))`
+	args := map[string]interface{}{
+		"pattern":   pattern,
+		"q":         q,
+		"users":     userMatched,
+		"institute": searchWeightInstitute,
+		"major":     searchWeightMajor,
+		"referrer":  searchWeightReferrer,
+		"intro":     searchWeightIntro,
+		"comment":   searchWeightComment,
+		"user":      searchWeightUser,
+	}
+	for k, v := range commentViewerArgs(viewer) {
+		args[k] = v
+	}
+
+	query := db.Model(&pkg.Application{}).
+		Select("applications.*, "+score+" AS \"searchScore\"", args).
+		Preload("InterviewAllocationsGroup").
+		Preload("InterviewAllocationsTeam").
+		Where("applications.\"recruitmentId\" = ?", rid)
+	if groups != nil {
+		query = query.Where("applications.\"group\" IN ?", groups)
+	}
+
+	var apps []pkg.Application
+	if err := query.
+		Where(`COALESCE(applications.institute, '') ILIKE @pattern OR COALESCE(applications.major, '') ILIKE @pattern OR
+			COALESCE(applications.referrer, '') ILIKE @pattern OR COALESCE(applications.intro, '') ILIKE @pattern OR
+			EXISTS (SELECT 1 FROM comments WHERE comments."applicationId" = applications.uid AND comments.content ILIKE @pattern AND `+visibleCommentSQL+`) OR
+			applications.uid::text IN @users`, args).
+		Order("\"searchScore\" DESC, applications.\"createdAt\" DESC").
+		Limit(limit).
+		Find(&apps).Error; err != nil {
+		return nil, err
+	}
+	return apps, nil
+}
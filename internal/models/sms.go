@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+)
+
+// CreateSMSMessages write sms into the outbox, all of them or none of them are written
+func CreateSMSMessages(msgs []*pkg.SMSMessage) error {
+	db := global.GetDB()
+	now := time.Now()
+	for _, msg := range msgs {
+		msg.Status = pkg.SMSPending
+		msg.NextAttemptAt = now
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&msgs).Error
+	})
+}
+
+// ClaimSMSMessages get the pending sms which are due and postpone them by lease,
+// so that they are retried after lease if the worker crashes while sending.
+// Rows locked by other workers are skipped.
+func ClaimSMSMessages(limit int, lease time.Duration) ([]pkg.SMSMessage, error) {
+	db := global.GetDB()
+	var msgs []pkg.SMSMessage
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND \"nextAttemptAt\" <= ?", pkg.SMSPending, now).
+			Order("\"nextAttemptAt\"").
+			Limit(limit).
+			Find(&msgs).Error; err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		uids := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			uids = append(uids, msg.Uid)
+		}
+		return tx.Model(&pkg.SMSMessage{}).
+			Where("uid IN ?", uids).
+			Update("\"nextAttemptAt\"", now.Add(lease)).Error
+	})
+	return msgs, err
+}
+
+// UpdateSMSAttempt save the result of an attempt to send sms
+func UpdateSMSAttempt(msg *pkg.SMSMessage) error {
+	db := global.GetDB()
+	return db.Model(&pkg.SMSMessage{}).
+		Where("uid = ?", msg.Uid).
+		Updates(map[string]interface{}{
+			"status":               msg.Status,
+			"attempts":             msg.Attempts,
+			"\"nextAttemptAt\"":    msg.NextAttemptAt,
+			"\"sentAt\"":           msg.SentAt,
+			"\"providerStatus\"":   msg.ProviderStatus,
+			"\"providerResponse\"": msg.ProviderResponse,
+			"\"lastError\"":        msg.LastError,
+		}).Error
+}
+
+// GetSMSMessagesByAid get the sms sent to the candidate of application, the latest first
+func GetSMSMessagesByAid(aid string) ([]pkg.SMSMessage, error) {
+	db := global.GetDB()
+	var msgs []pkg.SMSMessage
+	if err := db.Model(&pkg.SMSMessage{}).
+		Where("\"applicationId\" = ?", aid).
+		Order("\"createdAt\" DESC").
+		Find(&msgs).Error; err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
@@ -14,6 +14,7 @@ import (
 	"UniqueRecruitmentBackend/internal/controllers"
 	"UniqueRecruitmentBackend/internal/middlewares"
 	"UniqueRecruitmentBackend/internal/tracer"
+	"UniqueRecruitmentBackend/pkg/storage"
 )
 
 // NewRouter create backend http group routers
@@ -57,6 +58,10 @@ func NewRouter() *gin.Engine {
 		})
 	}
 
+	// presigned urls of local storage carry their own signature
+	r.GET(storage.LocalFilesPath+"/*key", controllers.ServeLocalFile)
+	r.PUT(storage.LocalFilesPath+"/*key", controllers.UploadLocalFile)
+
 	r.Use(middlewares.AuthMiddleware)
 	r.Use(middlewares.GlobalRoleMiddleWare)
 
@@ -73,12 +78,24 @@ func NewRouter() *gin.Engine {
 		//recruitmentRouter.PUT("/:rid/interviews/:name", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SetRecruitmentInterviews)
 		recruitmentRouter.POST("/:rid/interviews/:name", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.CreateRecruitmentInterviews)
 		recruitmentRouter.DELETE("/:rid/interviews/:name", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.DeleteRecruitmentInterviews)
+		recruitmentRouter.POST("/:rid/interviews/:name/allocate", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.AllocateRecruitmentInterviews)
+		recruitmentRouter.GET("/:rid/applications", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.ListApplications)
+		recruitmentRouter.GET("/:rid/applications/search", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SearchApplications)
+		recruitmentRouter.GET("/:rid/export", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.ExportApplications)
+		recruitmentRouter.GET("/:rid/analytics", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetRecruitmentAnalytics)
+		recruitmentRouter.GET("/:rid/rubrics", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetRubrics)
+		recruitmentRouter.PUT("/:rid/rubrics/:group", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SetRubric)
+		recruitmentRouter.GET("/:rid/reschedules", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetRecruitmentReschedules)
+		recruitmentRouter.GET("/:rid/interviews/:name/calendar.ics", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetRecruitmentCalendar)
+		recruitmentRouter.GET("/:rid/interviews/:name/panels", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetInterviewPanels)
 		recruitmentRouter.PUT("/:rid/file/:group/:type", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.UploadRecruitmentFile)
 
 		// admin role
 		recruitmentRouter.POST("/", middlewares.CheckAdminRoleMiddleWare, controllers.CreateRecruitment)
 		recruitmentRouter.PUT("/:rid/schedule", middlewares.CheckAdminRoleMiddleWare, controllers.UpdateRecruitment)
 		recruitmentRouter.PUT("/:rid/stressTest", middlewares.CheckAdminRoleMiddleWare, controllers.SetStressTestTime)
+		recruitmentRouter.PUT("/:rid/steps", middlewares.CheckAdminRoleMiddleWare, controllers.SetRecruitmentSteps)
+		recruitmentRouter.PUT("/:rid/blind", middlewares.CheckAdminRoleMiddleWare, controllers.SetRecruitmentBlindSteps)
 	}
 
 	applicationRouter := r.Group("/applications")
@@ -90,23 +107,54 @@ func NewRouter() *gin.Engine {
 		//applicationRouter.DELETE("/:aid", controllers.DeleteApplication)
 		applicationRouter.GET("/:aid/slots/:type", controllers.GetInterviewsSlots)
 		applicationRouter.GET("/:aid/resume", controllers.GetResume)
+		applicationRouter.GET("/:aid/resume/versions", controllers.GetResumeVersions)
+		applicationRouter.GET("/:aid/interviews.ics", controllers.GetApplicationCalendar)
 		applicationRouter.PUT("/:aid/slots/:type", controllers.SelectInterviewSlots)
+		applicationRouter.POST("/:aid/reschedule/:type", controllers.CreateRescheduleRequest)
 		applicationRouter.PUT("/:aid/abandoned", controllers.AbandonApplication)
 		applicationRouter.PUT("/:aid/file/:type", controllers.UploadAnswerFile)
 		applicationRouter.GET("/:aid/file/:type", controllers.DownloadAnswerFile)
+		applicationRouter.POST("/:aid/uploads", controllers.CreateUpload)
+		applicationRouter.PUT("/:aid/uploads/confirmed", controllers.ConfirmUpload)
 
 		// member
 		applicationRouter.PUT("/:aid/rejected", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.RejectApplication)
+		applicationRouter.PUT("/:aid/restored", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.RestoreApplication)
 		applicationRouter.GET("/recruitment/:rid", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetAllApplications)
+		applicationRouter.GET("/:aid/history", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetApplicationHistory)
 		applicationRouter.PUT("/:aid/step", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SetApplicationStep)
 		applicationRouter.PUT("/:aid/interviews/:type", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SetApplicationInterviewTime)
+		applicationRouter.PUT("/step", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SetApplicationsStep)
+		applicationRouter.PUT("/rejected", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.RejectApplications)
+		applicationRouter.GET("/:aid/evaluations", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetEvaluationRounds)
+		applicationRouter.PUT("/:aid/evaluations/:step/evaluators", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SetEvaluators)
+
+		// admin
+		applicationRouter.PUT("/:aid/evaluations/:step/revealed", middlewares.CheckAdminRoleMiddleWare, controllers.RevealComments)
+	}
+
+	interviewRouter := r.Group("/interviews")
+	{
+		// member
+		interviewRouter.GET("/today", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetMyInterviewsToday)
+		interviewRouter.PUT("/:iid/interviewers", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SignUpInterviewer)
+		interviewRouter.DELETE("/:iid/interviewers", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.WithdrawInterviewer)
 	}
 
 	commentRouter := r.Group("/comments")
 	{
 		// member
 		commentRouter.POST("/", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.CreateComment)
+		commentRouter.PUT("/:cid", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.UpdateComment)
 		commentRouter.DELETE("/:cid", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.DeleteComment)
+		commentRouter.GET("/:cid/revisions", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetCommentRevisions)
+	}
+
+	rescheduleRouter := r.Group("/reschedules")
+	{
+		// member
+		rescheduleRouter.PUT("/:id/approved", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.ApproveRescheduleRequest)
+		rescheduleRouter.PUT("/:id/denied", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.DenyRescheduleRequest)
 	}
 
 	smsRouter := r.Group("/sms")
@@ -114,6 +162,7 @@ func NewRouter() *gin.Engine {
 		// member
 		smsRouter.POST("/", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.SendSMS)
 		smsRouter.POST("/code", middlewares.CheckAdminRoleMiddleWare, controllers.SendCode)
+		smsRouter.GET("/messages", middlewares.CheckMemberRoleOrAdminMiddleWare, controllers.GetSMSMessages)
 	}
 
 	userRouter := r.Group("/user")
@@ -51,3 +51,26 @@ func GetTimeByString(joinTime string) time.Time {
 		return time.Date(y, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
 	}
 }
+
+// FormatLocalTime formats time in Asia/Shanghai to minutes, zero time is empty
+func FormatLocalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.In(localLocation()).Format("2006-01-02 15:04")
+}
+
+// LocalDay get the start and end of the day of t in Asia/Shanghai
+func LocalDay(t time.Time) (start, end time.Time) {
+	t = t.In(localLocation())
+	start = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 0, 1)
+}
+
+func localLocation() *time.Location {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		location = time.FixedZone("CST", 8*60*60)
+	}
+	return location
+}
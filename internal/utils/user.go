@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/xylonx/zapx"
+	"go.uber.org/zap"
+
+	"UniqueRecruitmentBackend/global"
+	"UniqueRecruitmentBackend/pkg"
+	"UniqueRecruitmentBackend/pkg/grpc"
+)
+
+const (
+	userCacheKeyPrefix = "sso:user:"
+	userCacheTTL       = 10 * time.Minute
+)
+
+// GetUsersByUIDs get users from redis cache first, the missing ones are got from sso by one batch rpc.
+// It never fails, users that can't be got are placeholders with only uid set.
+func GetUsersByUIDs(ctx context.Context, uids []string) map[string]*pkg.UserDetail {
+	users := make(map[string]*pkg.UserDetail)
+	uids = uniqueUIDs(uids)
+	if len(uids) == 0 {
+		return users
+	}
+
+	misses := getCachedUsers(ctx, uids, users)
+	if len(misses) != 0 {
+		fetched, err := grpc.GetUsers(misses)
+		if err != nil {
+			zapx.WithContext(ctx).Error("get users from sso failed", zap.Error(err), zap.Int("count", len(misses)))
+		}
+		for i := range fetched {
+			users[fetched[i].UID] = &fetched[i]
+		}
+		cacheUsers(ctx, fetched)
+	}
+
+	for _, uid := range uids {
+		if _, ok := users[uid]; !ok {
+			users[uid] = &pkg.UserDetail{UID: uid, Name: "未知用户", Placeholder: true}
+		}
+	}
+	return users
+}
+
+// FillApplicationsUserDetail set the user detail of candidates by GetUsersByUIDs
+func FillApplicationsUserDetail(ctx context.Context, apps []pkg.Application) {
+	uids := make([]string, 0, len(apps))
+	for _, app := range apps {
+		uids = append(uids, app.CandidateID)
+	}
+	users := GetUsersByUIDs(ctx, uids)
+	for i := range apps {
+		apps[i].UserDetail = users[apps[i].CandidateID]
+	}
+}
+
+// getCachedUsers put the cached users into users and returns uids not in cache,
+// all of them are missed if redis fails
+func getCachedUsers(ctx context.Context, uids []string, users map[string]*pkg.UserDetail) (misses []string) {
+	keys := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		keys = append(keys, userCacheKeyPrefix+uid)
+	}
+
+	values, err := global.GetRedisCli().MGet(ctx, keys...).Result()
+	if err != nil {
+		zapx.WithContext(ctx).Warn("get users from cache failed", zap.Error(err))
+		return uids
+	}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			misses = append(misses, uids[i])
+			continue
+		}
+		user := &pkg.UserDetail{}
+		if err := json.Unmarshal([]byte(data), user); err != nil {
+			misses = append(misses, uids[i])
+			continue
+		}
+		users[uids[i]] = user
+	}
+	return
+}
+
+func cacheUsers(ctx context.Context, users []pkg.UserDetail) {
+	if len(users) == 0 {
+		return
+	}
+	pipe := global.GetRedisCli().Pipeline()
+	for i := range users {
+		data, err := json.Marshal(&users[i])
+		if err != nil {
+			continue
+		}
+		pipe.Set(ctx, userCacheKeyPrefix+users[i].UID, data, userCacheTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		zapx.WithContext(ctx).Warn("cache users failed", zap.Error(err))
+	}
+}
+
+func uniqueUIDs(uids []string) []string {
+	seen := make(map[string]struct{}, len(uids))
+	res := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		if _, ok := seen[uid]; ok || uid == "" {
+			continue
+		}
+		seen[uid] = struct{}{}
+		res = append(res, uid)
+	}
+	return res
+}
@@ -0,0 +1,72 @@
+package pkg
+
+import "sort"
+
+// AllocateInterviews assigns every application one of the interviews it selected,
+// an interview can hold at most capacity[iid] applications.
+// It looks for augmenting paths (moving already placed candidates to another slot they selected),
+// so the number of placed candidates is maximal.
+// Interviews missing from capacity are not allocatable.
+func AllocateInterviews(apps []Application, capacity map[string]int) (allocated map[string]string, unallocated []string) {
+	// candidates with fewer choices go first, the earlier slot is preferred
+	order := make([]int, len(apps))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(apps[order[i]].InterviewSelections) < len(apps[order[j]].InterviewSelections)
+	})
+
+	choices := make([][]Interview, len(apps))
+	for i := range apps {
+		for _, interview := range apps[i].InterviewSelections {
+			if capacity[interview.Uid] > 0 {
+				choices[i] = append(choices[i], interview)
+			}
+		}
+		sort.SliceStable(choices[i], func(x, y int) bool {
+			return choices[i][x].Start.Before(choices[i][y].Start)
+		})
+	}
+
+	holders := make(map[string][]int) // iid -> index of apps
+	slotOf := make([]string, len(apps))
+
+	var augment func(app int, visited map[string]struct{}) bool
+	augment = func(app int, visited map[string]struct{}) bool {
+		for _, interview := range choices[app] {
+			if _, ok := visited[interview.Uid]; ok {
+				continue
+			}
+			visited[interview.Uid] = struct{}{}
+
+			if len(holders[interview.Uid]) < capacity[interview.Uid] {
+				holders[interview.Uid] = append(holders[interview.Uid], app)
+				slotOf[app] = interview.Uid
+				return true
+			}
+			for k, other := range holders[interview.Uid] {
+				if augment(other, visited) {
+					holders[interview.Uid][k] = app
+					slotOf[app] = interview.Uid
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for _, i := range order {
+		augment(i, make(map[string]struct{}))
+	}
+
+	allocated = make(map[string]string)
+	for i := range apps {
+		if slotOf[i] == "" {
+			unallocated = append(unallocated, apps[i].Uid)
+			continue
+		}
+		allocated[apps[i].Uid] = slotOf[i]
+	}
+	return
+}
@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllocateInterviews(t *testing.T) {
+	base := time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local)
+	morning := Interview{Common: Common{Uid: "morning"}, Start: base}
+	afternoon := Interview{Common: Common{Uid: "afternoon"}, Start: base.Add(6 * time.Hour)}
+
+	apps := []Application{
+		{Common: Common{Uid: "a"}, InterviewSelections: []Interview{morning, afternoon}},
+		{Common: Common{Uid: "b"}, InterviewSelections: []Interview{morning}},
+		{Common: Common{Uid: "c"}, InterviewSelections: []Interview{morning}},
+		{Common: Common{Uid: "d"}},
+	}
+
+	allocated, unallocated := AllocateInterviews(apps, map[string]int{"morning": 1, "afternoon": 1})
+	if len(allocated) != 2 {
+		t.Fatalf("expect 2 applications allocated, got %v", allocated)
+	}
+	if allocated["a"] != "afternoon" {
+		t.Fatalf("expect a to be moved to the afternoon, got %s", allocated["a"])
+	}
+	if len(unallocated) != 2 {
+		t.Fatalf("expect 2 applications unallocated, got %v", unallocated)
+	}
+	t.Log(allocated, unallocated)
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"sort"
+	"time"
+)
+
+// StepEventKind is how an application event changes the step the application stays in
+type StepEventKind int
+
+const (
+	StepEntered  StepEventKind = iota // the application is created at To
+	StepMoved                         // the application is moved from From to To
+	StepClosed                        // the application is abandoned or rejected
+	StepReopened                      // the application is restored
+)
+
+// StepEvent is the application event replayed by StepDurations
+type StepEvent struct {
+	ApplicationID string
+	At            time.Time
+	Kind          StepEventKind
+	From          Step
+	To            Step
+}
+
+// StepDurations replay the events of each application, and get how long it stayed in each step it has left.
+// Applications created before events were recorded are taken as signing up at their creation.
+// The time an application is abandoned/rejected before being restored is not counted.
+func StepDurations(apps []Application, events []StepEvent) map[string]map[Step]time.Duration {
+	type state struct {
+		step    Step
+		entered time.Time
+		closed  bool
+	}
+	states := make(map[string]*state, len(apps))
+	for _, app := range apps {
+		states[app.Uid] = &state{step: SignUp, entered: app.CreatedAt}
+	}
+
+	durations := make(map[string]map[Step]time.Duration)
+	for _, event := range events {
+		s, ok := states[event.ApplicationID]
+		if !ok {
+			continue
+		}
+		switch event.Kind {
+		case StepEntered:
+			s.step, s.entered = event.To, event.At
+		case StepMoved:
+			if !s.closed && s.step == event.From {
+				if durations[event.ApplicationID] == nil {
+					durations[event.ApplicationID] = make(map[Step]time.Duration)
+				}
+				durations[event.ApplicationID][s.step] += event.At.Sub(s.entered)
+			}
+			s.step, s.entered = event.To, event.At
+		case StepClosed:
+			s.closed = true
+		case StepReopened:
+			s.closed, s.entered = false, event.At
+		}
+	}
+	return durations
+}
+
+// BuildFunnel count the applications of each stage in the pipeline
+func BuildFunnel(pipeline Pipeline, apps []Application, durations map[string]map[Step]time.Duration) []FunnelStage {
+	var stages []FunnelStage
+	for _, step := range pipeline {
+		rank, _ := pipeline.Rank(step)
+		if rank > len(stages) {
+			stages = append(stages, FunnelStage{})
+		}
+		stages[rank-1].Steps = append(stages[rank-1].Steps, step)
+	}
+
+	stageDurations := make([][]time.Duration, len(stages))
+	for _, app := range apps {
+		rank, ok := pipeline.Rank(app.Step)
+		if !ok {
+			continue
+		}
+		for i := 0; i < rank; i++ {
+			stages[i].Reached++
+		}
+		stage := &stages[rank-1]
+		switch {
+		case app.Abandoned:
+			stage.Abandoned++
+		case app.Rejected:
+			stage.Rejected++
+		default:
+			stage.Current++
+		}
+
+		// steps sharing a rank are one stage, so their time is summed up
+		spent := make(map[int]time.Duration)
+		for step, duration := range durations[app.Uid] {
+			if rank, ok := pipeline.Rank(step); ok {
+				spent[rank] += duration
+			}
+		}
+		for rank, duration := range spent {
+			stageDurations[rank-1] = append(stageDurations[rank-1], duration)
+		}
+	}
+
+	for i := range stages {
+		if i+1 < len(stages) && stages[i].Reached != 0 {
+			conversion := float64(stages[i+1].Reached) / float64(stages[i].Reached)
+			stages[i].Conversion = &conversion
+		}
+		stages[i].MedianSeconds = medianSeconds(stageDurations[i])
+	}
+	return stages
+}
+
+func medianSeconds(durations []time.Duration) *int64 {
+	if len(durations) == 0 {
+		return nil
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	median := durations[len(durations)/2]
+	if len(durations)%2 == 0 {
+		median = (durations[len(durations)/2-1] + median) / 2
+	}
+	seconds := int64(median / time.Second)
+	return &seconds
+}
+
+// Breakdown count the applications by key, the largest group first
+func Breakdown(apps []Application, key func(app *Application) string) []AnalyticsBreakdown {
+	index := make(map[string]int)
+	var result []AnalyticsBreakdown
+	for i := range apps {
+		app := &apps[i]
+		k := key(app)
+		if _, ok := index[k]; !ok {
+			index[k] = len(result)
+			result = append(result, AnalyticsBreakdown{Key: k})
+		}
+		b := &result[index[k]]
+		b.Total++
+		switch {
+		case app.Abandoned:
+			b.Abandoned++
+		case app.Rejected:
+			b.Rejected++
+		case app.Step == Pass:
+			b.Passed++
+		}
+	}
+	for i := range result {
+		result[i].PassRate = float64(result[i].Passed) / float64(result[i].Total)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Total != result[j].Total {
+			return result[i].Total > result[j].Total
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
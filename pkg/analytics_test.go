@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildFunnel(t *testing.T) {
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	day := 24 * time.Hour
+	pipeline := Pipeline{SignUp, GroupInterview, OnlineGroupInterview, Pass}
+
+	apps := []Application{
+		{Common: Common{Uid: "a", CreatedAt: base}, Step: Pass},
+		{Common: Common{Uid: "b", CreatedAt: base}, Step: OnlineGroupInterview, Rejected: true},
+		{Common: Common{Uid: "c", CreatedAt: base}, Step: SignUp, Abandoned: true},
+		{Common: Common{Uid: "d", CreatedAt: base}, Step: SignUp},
+	}
+	setStep := func(aid string, from, to Step, at time.Duration) StepEvent {
+		return StepEvent{ApplicationID: aid, At: base.Add(at), Kind: StepMoved, From: from, To: to}
+	}
+	events := []StepEvent{
+		setStep("a", SignUp, GroupInterview, day),
+		// switching between online and offline interview stays in the stage
+		setStep("a", GroupInterview, OnlineGroupInterview, 2*day),
+		setStep("b", SignUp, GroupInterview, 3*day),
+		setStep("a", OnlineGroupInterview, Pass, 4*day),
+		setStep("b", GroupInterview, OnlineGroupInterview, 4*day),
+		// the time in the stage after b is rejected is not counted
+		{ApplicationID: "b", At: base.Add(5 * day), Kind: StepClosed},
+	}
+
+	funnel := BuildFunnel(pipeline, apps, StepDurations(apps, events))
+	if len(funnel) != 3 {
+		t.Fatalf("expect 3 stages, got %d", len(funnel))
+	}
+	if len(funnel[1].Steps) != 2 {
+		t.Fatalf("expect online and offline interview in a stage, got %v", funnel[1].Steps)
+	}
+
+	want := []struct {
+		reached, current, abandoned, rejected int
+		conversion                            float64
+		median                                time.Duration
+	}{
+		{4, 1, 1, 0, 0.5, 2 * day},
+		{2, 0, 0, 1, 0.5, 2 * day},
+		{1, 1, 0, 0, 0, 0},
+	}
+	for i, w := range want {
+		stage := funnel[i]
+		if stage.Reached != w.reached || stage.Current != w.current || stage.Abandoned != w.abandoned || stage.Rejected != w.rejected {
+			t.Fatalf("stage %d: unexpected counts %+v", i, stage)
+		}
+		if i == len(want)-1 {
+			if stage.Conversion != nil || stage.MedianSeconds != nil {
+				t.Fatalf("expect no conversion and median for the last stage, got %+v", stage)
+			}
+			continue
+		}
+		if stage.Conversion == nil || *stage.Conversion != w.conversion {
+			t.Fatalf("stage %d: expect conversion %v, got %v", i, w.conversion, stage.Conversion)
+		}
+		if stage.MedianSeconds == nil || *stage.MedianSeconds != int64(w.median/time.Second) {
+			t.Fatalf("stage %d: expect median %v, got %v", i, w.median, stage.MedianSeconds)
+		}
+	}
+}
+
+func TestStepDurationsRestore(t *testing.T) {
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	apps := []Application{{Common: Common{Uid: "a", CreatedAt: base}, Step: WrittenTest}}
+	events := []StepEvent{
+		{ApplicationID: "a", At: base.Add(time.Hour), Kind: StepClosed},
+		{ApplicationID: "a", At: base.Add(5 * time.Hour), Kind: StepReopened},
+		{ApplicationID: "a", At: base.Add(7 * time.Hour), Kind: StepMoved, From: SignUp, To: WrittenTest},
+	}
+	// the time between rejection and restoration is not counted
+	if spent := StepDurations(apps, events)["a"][SignUp]; spent != 2*time.Hour {
+		t.Fatalf("expect 2h at sign up, got %v", spent)
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	apps := []Application{
+		{Grade: "大二", Step: Pass},
+		{Grade: "大一", Rejected: true},
+		{Grade: "大二", Abandoned: true},
+	}
+	result := Breakdown(apps, func(app *Application) string { return app.Grade })
+	if len(result) != 2 || result[0].Key != "大二" || result[0].Total != 2 || result[0].Passed != 1 || result[0].PassRate != 0.5 {
+		t.Fatalf("unexpected breakdown %+v", result)
+	}
+}
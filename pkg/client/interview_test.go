@@ -14,25 +14,29 @@ const recruitmentID = "320772a9-a362-437b-a689-3eafa46625e7"
 func TestCreateInterviews(t *testing.T) {
 	cli, _ := NewClient(&Opts{Addr: localAddr})
 	err := cli.CreateInterview([]pkg.CreateInterviewOpts{{
-		Date:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
-		Period: pkg.Morning,
-		Start:  time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local),
-		End:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local),
+		Date:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		Period:     pkg.Morning,
+		Start:      time.Date(2024, 5, 1, 8, 0, 0, 0, time.Local),
+		End:        time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local),
+		SlotNumber: 10,
 	}, {
-		Date:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
-		Period: pkg.Afternoon,
-		Start:  time.Date(2024, 5, 1, 14, 0, 0, 0, time.Local),
-		End:    time.Date(2024, 5, 1, 18, 0, 0, 0, time.Local),
+		Date:       time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		Period:     pkg.Afternoon,
+		Start:      time.Date(2024, 5, 1, 14, 0, 0, 0, time.Local),
+		End:        time.Date(2024, 5, 1, 18, 0, 0, 0, time.Local),
+		SlotNumber: 10,
 	}, {
-		Date:   time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local),
-		Period: pkg.Morning,
-		Start:  time.Date(2024, 5, 2, 8, 0, 0, 0, time.Local),
-		End:    time.Date(2024, 5, 2, 12, 0, 0, 0, time.Local),
+		Date:       time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local),
+		Period:     pkg.Morning,
+		Start:      time.Date(2024, 5, 2, 8, 0, 0, 0, time.Local),
+		End:        time.Date(2024, 5, 2, 12, 0, 0, 0, time.Local),
+		SlotNumber: 10,
 	}, {
-		Date:   time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local),
-		Period: pkg.Afternoon,
-		Start:  time.Date(2024, 5, 2, 14, 0, 0, 0, time.Local),
-		End:    time.Date(2024, 5, 2, 18, 0, 0, 0, time.Local),
+		Date:       time.Date(2024, 5, 2, 0, 0, 0, 0, time.Local),
+		Period:     pkg.Afternoon,
+		Start:      time.Date(2024, 5, 2, 14, 0, 0, 0, time.Local),
+		End:        time.Date(2024, 5, 2, 18, 0, 0, 0, time.Local),
+		SlotNumber: 10,
 	},
 	}, recruitmentID, pkg.Web)
 
@@ -44,6 +44,12 @@ const (
 	Oth    Gender = 3
 )
 
+var EnToZhGenderMap = map[Gender]string{
+	Male:   "男",
+	Female: "女",
+	Oth:    "其他",
+}
+
 type Grade string
 
 const (
@@ -94,6 +100,21 @@ var StepRanks = map[Step]int{
 	OnlineTeamInterview:  7,
 	Pass:                 8,
 }
+
+// DefaultPipeline is the steps of a recruitment which doesn't set its own steps
+var DefaultPipeline = Pipeline{
+	SignUp,
+	WrittenTest,
+	GroupTimeSelection,
+	GroupInterview,
+	OnlineGroupInterview,
+	StressTest,
+	TeamTimeSelection,
+	TeamInterview,
+	OnlineTeamInterview,
+	Pass,
+}
+
 var EnToZhStepMap = map[Step]string{
 	SignUp:               "报名",
 	WrittenTest:          "笔试",
@@ -135,6 +156,20 @@ const (
 	Bad    Evaluation = 3
 )
 
+var EnToZhEvaluationMap = map[Evaluation]string{
+	Good:   "好",
+	Normal: "中",
+	Bad:    "差",
+}
+
+type CommentVisibility string
+
+const (
+	CommentVisibleToGroup   CommentVisibility = "group"   // members in the group of application
+	CommentVisibleToMembers CommentVisibility = "members" // all members
+	CommentVisibleToAdmins  CommentVisibility = "admins"
+)
+
 type Role string
 
 const (
@@ -160,6 +195,8 @@ const (
 	Delay                   SMSTemplateType = "delay"
 	OnLineGroupInterviewSMS SMSTemplateType = "onlineGroupInterview"
 	OnLineTeamInterviewSMS  SMSTemplateType = "onlineTeamInterview"
+	RescheduleApproval      SMSTemplateType = "rescheduleApproved" // sms template id is configured by deployment
+	RescheduleDenial        SMSTemplateType = "rescheduleDenied"   // sms template id is configured by deployment
 )
 
 var SMSTemplateMap = map[SMSTemplateType]uint{
@@ -172,6 +209,53 @@ var SMSTemplateMap = map[SMSTemplateType]uint{
 	OnLineTeamInterviewSMS:  1533302,
 }
 
+// EmailTemplateMap is the alias of email templates in open platform, templates shared with sms take the same params
+var EmailTemplateMap = map[SMSTemplateType]string{
+	StateChange:             "recruitment-state-change",
+	VerificationCode:        "recruitment-verification-code",
+	Interviews:              "recruitment-interviews",
+	PassSMS:                 "recruitment-pass",
+	Delay:                   "recruitment-delay",
+	OnLineGroupInterviewSMS: "recruitment-online-group-interview",
+	OnLineTeamInterviewSMS:  "recruitment-online-team-interview",
+	// {1}你好，你的{2}已改到{3}
+	RescheduleApproval: "recruitment-reschedule-approved",
+	// {1}你好，你的{2}改期申请未通过：{3}
+	RescheduleDenial: "recruitment-reschedule-denied",
+}
+
+const EmailSubject = "联创团队招新通知"
+
+type NotifyChannel string
+
+const (
+	ChannelSMS   NotifyChannel = "sms"
+	ChannelEmail NotifyChannel = "email"
+)
+
+type RescheduleStatus string
+
+const (
+	ReschedulePending  RescheduleStatus = "pending"
+	RescheduleApproved RescheduleStatus = "approved"
+	RescheduleDenied   RescheduleStatus = "denied"
+)
+
+type SMSStatus string
+
+const (
+	SMSPending SMSStatus = "pending" // waiting to be sent or retried
+	SMSSent    SMSStatus = "sent"
+	SMSFailed  SMSStatus = "failed" // gave up after max attempts
+)
+
+type UploadType string
+
+const (
+	UploadResume UploadType = "resume"
+	UploadAnswer UploadType = "answer"
+)
+
 const (
 	SessionNameUID       = "X-UniqueSSO-UID"
 	SessionMaxAgeSeconds = 4 * 60 * 60 // seconds
@@ -0,0 +1,113 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"UniqueRecruitmentBackend/configs"
+	"UniqueRecruitmentBackend/pkg"
+	pb "UniqueRecruitmentBackend/pkg/proto/open_platform"
+)
+
+type EmailBody struct {
+	To       string
+	Subject  string
+	Template pkg.SMSTemplateType
+	Params   []string
+}
+
+// Sender sends email by the EmailService of unique open-platform
+type Sender struct {
+	client      pb.EmailServiceClient
+	templateIDs map[string]string // alias -> template id
+}
+
+var defaultSender *Sender
+
+// Setup connects to open platform and resolves the aliases in pkg.EmailTemplateMap,
+// email is disabled if the address isn't configured
+func Setup() error {
+	if configs.Config.Email.Addr == "" {
+		return nil
+	}
+
+	conn, err := grpc.Dial(configs.Config.Email.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	sender := &Sender{client: pb.NewEmailServiceClient(conn)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := sender.loadTemplates(ctx); err != nil {
+		return err
+	}
+	defaultSender = sender
+	return nil
+}
+
+func (s *Sender) loadTemplates(ctx context.Context) error {
+	resp, err := s.client.GetAllEmailTemplates(ctx, &pb.GetAllEmailTemplatesRequest{})
+	if err != nil {
+		return fmt.Errorf("get email templates failed, %w", err)
+	}
+
+	s.templateIDs = make(map[string]string)
+	for _, template := range resp.GetTemplates() {
+		s.templateIDs[template.GetAlias()] = template.GetTemplateId()
+	}
+
+	var missing []string
+	for _, alias := range pkg.EmailTemplateMap {
+		if _, ok := s.templateIDs[alias]; !ok {
+			missing = append(missing, alias)
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("email templates %v don't exist in open platform", missing)
+	}
+	return nil
+}
+
+// Send pushes email to open-platform, status is the grpc status code
+func (s *Sender) Send(ctx context.Context, body EmailBody) (int, string, error) {
+	alias, ok := pkg.EmailTemplateMap[body.Template]
+	if !ok {
+		return 0, "", fmt.Errorf("email template of %s is not set", body.Template)
+	}
+
+	_, err := s.client.PushEmail(ctx, &pb.PushEmailRequest{
+		To:         body.To,
+		Subject:    body.Subject,
+		TemplateId: s.templateIDs[alias],
+		Params:     body.Params,
+	})
+	if err != nil {
+		st := status.Convert(err)
+		return int(st.Code()), st.Message(), err
+	}
+	return 0, "", nil
+}
+
+// Enabled reports whether email is configured
+func Enabled() bool {
+	return defaultSender != nil
+}
+
+// Deliver sends email by the sender connected in Setup
+func Deliver(body EmailBody) (status int, response string, err error) {
+	if defaultSender == nil {
+		return 0, "", errors.New("email is not configured")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return defaultSender.Send(ctx, body)
+}
@@ -0,0 +1,133 @@
+// Package ical generates iCalendar (RFC 5545) feeds of interviews.
+package ical
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	TZID       = "Asia/Shanghai"
+	prodID     = "-//UniqueStudio//Recruitment//CN"
+	lineLength = 75 // octets, excluding CRLF
+)
+
+// Location is Asia/Shanghai, it falls back to the fixed offset if tzdata is missing,
+// Shanghai has no daylight saving time so they are the same
+var Location = loadLocation()
+
+func loadLocation() *time.Location {
+	location, err := time.LoadLocation(TZID)
+	if err != nil {
+		return time.FixedZone("CST", 8*60*60)
+	}
+	return location
+}
+
+// Event is a VEVENT, events with the same UID replace each other in calendar clients,
+// the one with larger Sequence wins
+type Event struct {
+	UID         string
+	Sequence    int64
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Modified    time.Time
+	Cancelled   bool
+}
+
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+// Marshal returns the calendar with CRLF line endings and folded lines
+func (c *Calendar) Marshal() []byte {
+	w := &writer{}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + prodID)
+	w.line("CALSCALE:GREGORIAN")
+	if c.Name != "" {
+		w.line("X-WR-CALNAME:" + escape(c.Name))
+	}
+	w.line("X-WR-TIMEZONE:" + TZID)
+	writeTimezone(w)
+	for i := range c.Events {
+		writeEvent(w, &c.Events[i])
+	}
+	w.line("END:VCALENDAR")
+	return w.buf.Bytes()
+}
+
+// writeTimezone writes Asia/Shanghai, which is UTC+8 all the year
+func writeTimezone(w *writer) {
+	w.line("BEGIN:VTIMEZONE")
+	w.line("TZID:" + TZID)
+	w.line("BEGIN:STANDARD")
+	w.line("DTSTART:19700101T000000")
+	w.line("TZOFFSETFROM:+0800")
+	w.line("TZOFFSETTO:+0800")
+	w.line("TZNAME:CST")
+	w.line("END:STANDARD")
+	w.line("END:VTIMEZONE")
+}
+
+func writeEvent(w *writer, e *Event) {
+	w.line("BEGIN:VEVENT")
+	w.line("UID:" + escape(e.UID))
+	w.line("DTSTAMP:" + e.Modified.UTC().Format("20060102T150405Z"))
+	w.line("LAST-MODIFIED:" + e.Modified.UTC().Format("20060102T150405Z"))
+	w.line(fmt.Sprintf("SEQUENCE:%d", e.Sequence))
+	w.line("DTSTART;TZID=" + TZID + ":" + localTime(e.Start))
+	w.line("DTEND;TZID=" + TZID + ":" + localTime(e.End))
+	w.line("SUMMARY:" + escape(e.Summary))
+	if e.Description != "" {
+		w.line("DESCRIPTION:" + escape(e.Description))
+	}
+	if e.Location != "" {
+		w.line("LOCATION:" + escape(e.Location))
+	}
+	if e.Cancelled {
+		w.line("STATUS:CANCELLED")
+	} else {
+		w.line("STATUS:CONFIRMED")
+	}
+	w.line("END:VEVENT")
+}
+
+func localTime(t time.Time) string {
+	return t.In(Location).Format("20060102T150405")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(text string) string {
+	return escaper.Replace(text)
+}
+
+type writer struct {
+	buf bytes.Buffer
+}
+
+// line writes a content line, folding it at 75 octets without splitting a utf-8 character
+func (w *writer) line(s string) {
+	limit := lineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.buf.WriteString(s[:cut])
+		w.buf.WriteString("\r\n ")
+		s = s[cut:]
+		limit = lineLength - 1 // the leading space of continuation line
+	}
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
@@ -0,0 +1,46 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestCalendarMarshal(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC) // 08:00 in Shanghai
+	cal := &Calendar{
+		Name: "web 组面",
+		Events: []Event{
+			{
+				UID:         "interview-1@hr.hustunique.com",
+				Summary:     "组面; web, lab",
+				Description: strings.Repeat("联创团队", 20),
+				Start:       start,
+				End:         start.Add(time.Hour),
+				Modified:    start,
+			},
+			{UID: "interview-2@hr.hustunique.com", Start: start, End: start, Modified: start, Cancelled: true},
+		},
+	}
+	out := string(cal.Marshal())
+
+	for _, want := range []string{
+		"DTSTART;TZID=Asia/Shanghai:20240501T080000\r\n",
+		"DTEND;TZID=Asia/Shanghai:20240501T090000\r\n",
+		`SUMMARY:组面\; web\, lab` + "\r\n",
+		"STATUS:CANCELLED\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expect %q in calendar:\n%s", want, out)
+		}
+	}
+	for _, line := range strings.Split(out, "\r\n") {
+		if len(line) > lineLength {
+			t.Fatalf("line is not folded: %q", line)
+		}
+		if !utf8.ValidString(line) {
+			t.Fatalf("utf-8 character is split: %q", line)
+		}
+	}
+}
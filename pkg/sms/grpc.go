@@ -0,0 +1,66 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "UniqueRecruitmentBackend/pkg/proto/open_platform"
+)
+
+// GRPCSender sends sms by the SMSService of unique open-platform
+type GRPCSender struct {
+	client pb.SMSServiceClient
+	signID string
+}
+
+func NewGRPCSender(addr string, signID string) (*GRPCSender, error) {
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCSender{client: pb.NewSMSServiceClient(conn), signID: signID}, nil
+}
+
+// Send pushes sms to open-platform, status is the grpc status code
+func (s *GRPCSender) Send(ctx context.Context, smsBody SMSBody) (int, string, error) {
+	resp, err := s.client.PushSMS(ctx, &pb.PushSMSRequest{
+		SignId:     s.signID,
+		TemplateId: formatTemplateID(smsBody.TemplateID),
+		Phone:      smsBody.Phone,
+		Params:     smsBody.Params,
+	})
+	if err != nil {
+		st := status.Convert(err)
+		return int(st.Code()), st.Message(), err
+	}
+	return 0, fmt.Sprintf("fee: %d", resp.GetFee()), nil
+}
+
+func (s *GRPCSender) CheckTemplates(ctx context.Context, templateIDs []uint) error {
+	resp, err := s.client.GetAllSMSTemplates(ctx, &pb.GetAllSMSTemplatesRequest{})
+	if err != nil {
+		return fmt.Errorf("get sms templates failed, %w", err)
+	}
+
+	existed := make(map[string]struct{})
+	for _, template := range resp.GetTemplates() {
+		existed[template.GetTemplateId()] = struct{}{}
+	}
+
+	var missing []string
+	for _, id := range templateIDs {
+		if _, ok := existed[formatTemplateID(id)]; !ok {
+			missing = append(missing, formatTemplateID(id))
+		}
+	}
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("sms templates %v don't exist in open platform", missing)
+	}
+	return nil
+}
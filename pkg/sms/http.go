@@ -0,0 +1,54 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+)
+
+const openPlatformSMSURL = "https://open.hustunique.com/sms/send_single"
+
+// HTTPSender sends sms by the http api of unique open-platform
+type HTTPSender struct {
+	Token string
+}
+
+// Send sends sms request to unique open-platform, a non-2xx response is treated as failure
+func (s *HTTPSender) Send(ctx context.Context, smsBody SMSBody) (int, string, error) {
+	body, err := json.Marshal(smsBody)
+	if err != nil {
+		log.Println("marshal: ", err)
+		return 0, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", openPlatformSMSURL, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, "", err
+	}
+
+	req.Header.Set("AccessKey", s.Token)
+	cli := http.Client{}
+	resp, err := cli.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return resp.StatusCode, "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(respBody), fmt.Errorf("open platform responds %s", resp.Status)
+	}
+	return resp.StatusCode, string(respBody), nil
+}
+
+// CheckTemplates always passes, the http api can't list templates
+func (s *HTTPSender) CheckTemplates(ctx context.Context, templateIDs []uint) error {
+	return nil
+}
@@ -1,12 +1,13 @@
 package sms
 
 import (
-	"bytes"
-	"encoding/json"
-	"log"
-	"net/http"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
 
 	"UniqueRecruitmentBackend/configs"
+	"UniqueRecruitmentBackend/pkg"
 )
 
 type SMSType string
@@ -17,29 +18,61 @@ type SMSBody struct {
 	Params     []string `json:"template_param_set"`
 }
 
-// SendSMS sends sms request to unique open-platform
-func SendSMS(smsBody SMSBody) (*http.Response, error) {
-	body, err := json.Marshal(smsBody)
-	if err != nil {
-		log.Println("marshal: ", err)
-		return nil, err
-	}
+// Sender sends sms through a provider
+type Sender interface {
+	// Send returns the status code and response of provider for the record,
+	// err is not nil if the provider refuses the sms
+	Send(ctx context.Context, smsBody SMSBody) (status int, response string, err error)
+	// CheckTemplates returns error if any of the templates doesn't exist in the provider
+	CheckTemplates(ctx context.Context, templateIDs []uint) error
+}
+
+const (
+	DriverHTTP = "http"
+	DriverGRPC = "grpc"
+)
+
+var defaultSender Sender = &HTTPSender{}
 
-	req, err := http.NewRequest(
-		"POST",
-		"https://open.hustunique.com/sms/send_single",
-		bytes.NewBuffer(body))
+// Setup selects the sender by configs and checks pkg.SMSTemplateMap against it
+func Setup() error {
+	switch configs.Config.SMS.Driver {
+	case "", DriverHTTP:
+		defaultSender = &HTTPSender{Token: configs.Config.SMS.Token}
+	case DriverGRPC:
+		sender, err := NewGRPCSender(configs.Config.SMS.Addr, configs.Config.SMS.SignID)
+		if err != nil {
+			return err
+		}
+		defaultSender = sender
+	default:
+		return fmt.Errorf("sms driver %s is invalid", configs.Config.SMS.Driver)
+	}
 
-	if err != nil {
-		return nil, err
+	// the templates of reschedule results are created by deployment
+	if id := configs.Config.SMS.RescheduleApprovedTemplateID; id != 0 {
+		pkg.SMSTemplateMap[pkg.RescheduleApproval] = id
+	}
+	if id := configs.Config.SMS.RescheduleDeniedTemplateID; id != 0 {
+		pkg.SMSTemplateMap[pkg.RescheduleDenial] = id
 	}
 
-	req.Header.Set("AccessKey", configs.Config.SMS.Token)
-	cli := http.Client{}
-	resp, err := cli.Do(req)
-	if err != nil {
-		return nil, err
+	templateIDs := make([]uint, 0, len(pkg.SMSTemplateMap))
+	for _, id := range pkg.SMSTemplateMap {
+		templateIDs = append(templateIDs, id)
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return defaultSender.CheckTemplates(ctx, templateIDs)
+}
+
+// Deliver sends sms by the sender selected in Setup
+func Deliver(smsBody SMSBody) (status int, response string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return defaultSender.Send(ctx, smsBody)
+}
 
-	return resp, nil
+func formatTemplateID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
 }
@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tencentyun/cos-go-sdk-v5"
+	"github.com/tencentyun/cos-go-sdk-v5/debug"
+)
+
+// COSStorage stores objects in a bucket of Tencent COS
+type COSStorage struct {
+	client    *cos.Client
+	secretID  string
+	secretKey string
+}
+
+// NewCOSStorage creates the storage by the bucket url like https://examplebucket-1250000000.cos.ap-guangzhou.myqcloud.com
+func NewCOSStorage(bucketURL, secretID, secretKey string) (*COSStorage, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil {
+		return nil, err
+	}
+	client := cos.NewClient(&cos.BaseURL{BucketURL: u}, &http.Client{
+		Transport: &cos.AuthorizationTransport{
+			SecretID:  secretID,
+			SecretKey: secretKey,
+			Transport: &debug.DebugRequestTransport{
+				RequestHeader: true,
+				// Notice when put a large file and set need the request body, might happend out of memory rerror.
+				RequestBody:    false,
+				ResponseHeader: true,
+				ResponseBody:   false,
+			},
+		},
+		Timeout: 60 * time.Second,
+	})
+	return &COSStorage{client: client, secretID: secretID, secretKey: secretKey}, nil
+}
+
+func (s *COSStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	opt := &cos.ObjectPutOptions{ObjectPutHeaderOptions: &cos.ObjectPutHeaderOptions{ContentType: contentType}}
+	if size > 0 {
+		opt.ContentLength = size
+	}
+	_, err := s.client.Object.Put(ctx, key, body, opt)
+	return err
+}
+
+func (s *COSStorage) Get(ctx context.Context, key string) (*Object, error) {
+	resp, err := s.client.Object.Get(ctx, key, nil)
+	if err != nil {
+		return nil, cosError(err)
+	}
+	return &Object{
+		Body:          resp.Body,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+func (s *COSStorage) Presign(ctx context.Context, key string, expire time.Duration) (string, error) {
+	u, err := s.client.Object.GetPresignedURL(ctx, http.MethodGet, key, s.secretID, s.secretKey, expire, nil)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *COSStorage) PresignPut(ctx context.Context, key string, contentType string, size int64, expire time.Duration) (string, error) {
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.FormatInt(size, 10))
+	u, err := s.client.Object.GetPresignedURL(ctx, http.MethodPut, key, s.secretID, s.secretKey, expire, &cos.PresignedURLOptions{Header: &header})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *COSStorage) Delete(ctx context.Context, key string) error {
+	_, err := s.client.Object.Delete(ctx, key)
+	if err != nil && !cos.IsNotFoundError(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *COSStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, err := s.client.Object.Head(ctx, key, nil)
+	if err != nil {
+		return nil, cosError(err)
+	}
+	info := &ObjectInfo{
+		Key:           key,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}
+	info.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+func (s *COSStorage) Copy(ctx context.Context, src, dst string) error {
+	sourceURL := s.client.BaseURL.BucketURL.Host + "/" + src
+	_, _, err := s.client.Object.Copy(ctx, dst, sourceURL, nil)
+	return cosError(err)
+}
+
+func (s *COSStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	opt := &cos.BucketGetOptions{Prefix: prefix, MaxKeys: 1000}
+	for {
+		result, _, err := s.client.Bucket.Get(ctx, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range result.Contents {
+			info := ObjectInfo{Key: object.Key, ContentLength: object.Size}
+			info.LastModified, _ = time.Parse(time.RFC3339, object.LastModified)
+			infos = append(infos, info)
+		}
+		if !result.IsTruncated {
+			return infos, nil
+		}
+		// NextMarker is only returned with delimiter, otherwise the last key is the marker
+		opt.Marker = result.NextMarker
+		if opt.Marker == "" && len(result.Contents) > 0 {
+			opt.Marker = result.Contents[len(result.Contents)-1].Key
+		}
+	}
+}
+
+func cosError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if cos.IsNotFoundError(err) {
+		return ErrNotFound
+	}
+	// HEAD responses have no body, so the code of error is empty
+	var errResp *cos.ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return err
+}
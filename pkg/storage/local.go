@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidKey       = errors.New("the file key is invalid")
+	ErrInvalidSignature = errors.New("the signature of url is invalid")
+	ErrURLExpired       = errors.New("the url has expired")
+)
+
+// LocalFilesPath is the route serving the presigned urls of LocalStorage
+const LocalFilesPath = "/files"
+
+// LocalStorage stores objects as files under root, which is useful for development and tests.
+// Presigned urls point to LocalFilesPath of the backend itself and are signed with HMAC-SHA256
+type LocalStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// NewLocalStorage creates the storage, root is created if it doesn't exist.
+// baseURL is the address of the backend like http://localhost:3333
+func NewLocalStorage(root, baseURL, secret string) (*LocalStorage, error) {
+	if root == "" {
+		return nil, errors.New("root of local storage is empty")
+	}
+	if secret == "" {
+		return nil, errors.New("secret of local storage is empty")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStorage{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  []byte(secret),
+	}, nil
+}
+
+// path converts key to the file path, keys escaping root are refused
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if key == "" || cleaned == "/" || cleaned != "/"+key {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(cleaned)), nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (*Object, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, localError(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, ErrNotFound
+	}
+	return &Object{Body: f, ContentLength: info.Size(), ContentType: contentTypeOf(key)}, nil
+}
+
+func (s *LocalStorage) Presign(ctx context.Context, key string, expire time.Duration) (string, error) {
+	return s.presign(key, expire, http.MethodGet)
+}
+
+func (s *LocalStorage) PresignPut(ctx context.Context, key string, contentType string, size int64, expire time.Duration) (string, error) {
+	return s.presign(key, expire, http.MethodPut, contentType, strconv.FormatInt(size, 10))
+}
+
+// presign signs the method, key, expires and the extra parts which the request must match
+func (s *LocalStorage) presign(key string, expire time.Duration, method string, extra ...string) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(expire).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.sign(append([]string{method, key, expires}, extra...)...))
+	return fmt.Sprintf("%s%s/%s?%s", s.baseURL, LocalFilesPath, escapeKey(key), query.Encode()), nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, localError(err)
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{
+		Key:           key,
+		ContentLength: info.Size(),
+		ContentType:   contentTypeOf(key),
+		LastModified:  info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) Copy(ctx context.Context, src, dst string) error {
+	obj, err := s.Get(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer obj.Body.Close()
+	return s.Put(ctx, dst, obj.Body, obj.ContentLength, obj.ContentType)
+}
+
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// only walk the directory containing prefix
+	dir := s.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+prefix[:i])))
+	}
+
+	var infos []ObjectInfo
+	err := filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		// skip the temporary files of Put
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		infos = append(infos, ObjectInfo{
+			Key:           key,
+			ContentLength: info.Size(),
+			ContentType:   contentTypeOf(key),
+			LastModified:  info.ModTime(),
+		})
+		return nil
+	})
+	return infos, err
+}
+
+// Verify checks the expires and signature in the query of url got from Presign
+func (s *LocalStorage) Verify(key, expires, signature string) error {
+	return s.verify(expires, signature, http.MethodGet, key, expires)
+}
+
+// VerifyPut checks the expires and signature in the query of url got from PresignPut,
+// and the Content-Type and Content-Length of the upload request
+func (s *LocalStorage) VerifyPut(key, expires, signature, contentType string, size int64) error {
+	return s.verify(expires, signature, http.MethodPut, key, expires, contentType, strconv.FormatInt(size, 10))
+}
+
+func (s *LocalStorage) verify(expires, signature string, parts ...string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(s.sign(parts...)), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *LocalStorage) sign(parts ...string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func localError(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func contentTypeOf(key string) string {
+	if contentType := mime.TypeByExtension(path.Ext(key)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// escapeKey escapes each segment of key, keeping the slashes
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalStorage(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir(), "http://localhost:3333/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "2023秋/web/uid/resume.pdf"
+	if err = s.Put(ctx, key, strings.NewReader("resume"), -1, "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(obj.Body)
+	obj.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "resume" || obj.ContentLength != 6 || obj.ContentType != "application/pdf" {
+		t.Errorf("Get() = %q, %d, %s", content, obj.ContentLength, obj.ContentType)
+	}
+
+	info, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ContentLength != 6 {
+		t.Errorf("Stat().ContentLength = %d, want 6", info.ContentLength)
+	}
+
+	if err = s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if err = s.Delete(ctx, key); err != nil {
+		t.Errorf("Delete() of missing file error = %v", err)
+	}
+
+	for _, invalid := range []string{"", "../secret", "a/../../b", "/abs"} {
+		if err = s.Put(ctx, invalid, strings.NewReader(""), 0, ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", invalid, err)
+		}
+	}
+}
+
+func TestLocalStoragePresign(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir(), "http://localhost:3333/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "2023秋/web/uid/my resume.pdf"
+	raw, err := s.Presign(context.Background(), key, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, "http://localhost:3333/files/") {
+		t.Errorf("Presign() = %s", raw)
+	}
+
+	got := strings.TrimPrefix(u.Path, LocalFilesPath+"/")
+	if got != key {
+		t.Errorf("key in url = %q, want %q", got, key)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	if err = s.Verify(got, expires, signature); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+	if err = s.Verify("2023秋/web/other/resume.pdf", expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() of other key error = %v, want ErrInvalidSignature", err)
+	}
+	if err = s.Verify(got, expires+"0", signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() of modified expires error = %v, want ErrInvalidSignature", err)
+	}
+
+	raw, _ = s.Presign(context.Background(), key, -time.Minute)
+	u, _ = url.Parse(raw)
+	if err = s.Verify(key, u.Query().Get("expires"), u.Query().Get("signature")); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("Verify() of expired url error = %v, want ErrURLExpired", err)
+	}
+
+	raw, err = s.PresignPut(context.Background(), key, "application/pdf", 1024, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ = url.Parse(raw)
+	expires, signature = u.Query().Get("expires"), u.Query().Get("signature")
+	if err = s.VerifyPut(key, expires, signature, "application/pdf", 1024); err != nil {
+		t.Errorf("VerifyPut() error = %v", err)
+	}
+	if err = s.VerifyPut(key, expires, signature, "application/pdf", 2048); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifyPut() of other size error = %v, want ErrInvalidSignature", err)
+	}
+	if err = s.Verify(key, expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify() of upload url error = %v, want ErrInvalidSignature", err)
+	}
+}
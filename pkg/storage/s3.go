@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm      = "AWS4-HMAC-SHA256"
+	s3UnsignedBody   = "UNSIGNED-PAYLOAD"
+	s3TimeFormat     = "20060102T150405Z"
+	s3DateFormat     = "20060102"
+	s3MaxPresignTime = 7 * 24 * time.Hour
+)
+
+// S3Storage stores objects in a bucket of S3 compatible services such as MinIO.
+// Requests are signed with AWS Signature Version 4 and use path-style urls
+type S3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3Storage creates the storage, endpoint is like http://localhost:9000 or https://s3.us-east-1.amazonaws.com
+func NewS3Storage(endpoint, region, bucket, accessKey, secretKey string) (*S3Storage, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint %s is invalid", endpoint)
+	}
+	if bucket == "" {
+		return nil, errors.New("s3 bucket is empty")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3Storage{
+		endpoint:  u,
+		region:    region,
+		bucket:    bucket,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+func (s *S3Storage) objectURL(key string) *url.URL {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = awsEscape(segments[i])
+	}
+	u := *s.endpoint
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + "/" + awsEscape(s.bucket) + "/" + strings.Join(segments, "/")
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.bucket + "/" + key
+	return &u
+}
+
+func (s *S3Storage) bucketURL(query url.Values) *url.URL {
+	u := *s.endpoint
+	u.RawPath = strings.TrimSuffix(s.endpoint.EscapedPath(), "/") + "/" + awsEscape(s.bucket)
+	u.Path = strings.TrimSuffix(s.endpoint.Path, "/") + "/" + s.bucket
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (s *S3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	return s.send(ctx, method, s.objectURL(key), body, size, header)
+}
+
+func (s *S3Storage) send(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	s.signRequest(req, s3UnsignedBody, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("s3 %s %s failed with status %d: %s", method, u.Path, resp.StatusCode, msg)
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	// S3 refuses chunked uploads without content length, so the body is buffered if its size is unknown
+	if size < 0 {
+		buf, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		body, size = bytes.NewReader(buf), int64(len(buf))
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, body, size, header)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (*Object, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{
+		Body:          resp.Body,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}, nil
+}
+
+func (s *S3Storage) Presign(ctx context.Context, key string, expire time.Duration) (string, error) {
+	if expire > s3MaxPresignTime {
+		expire = s3MaxPresignTime
+	}
+	return s.presignURL(http.MethodGet, s.objectURL(key), nil, time.Now(), expire), nil
+}
+
+func (s *S3Storage) PresignPut(ctx context.Context, key string, contentType string, size int64, expire time.Duration) (string, error) {
+	if expire > s3MaxPresignTime {
+		expire = s3MaxPresignTime
+	}
+	headers := map[string]string{
+		"content-type":   contentType,
+		"content-length": strconv.FormatInt(size, 10),
+	}
+	return s.presignURL(http.MethodPut, s.objectURL(key), headers, time.Now(), expire), nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := &ObjectInfo{
+		Key:           key,
+		ContentLength: resp.ContentLength,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}
+	info.LastModified, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, nil
+}
+
+func (s *S3Storage) Copy(ctx context.Context, src, dst string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", s.objectURL(src).EscapedPath())
+	resp, err := s.do(ctx, http.MethodPut, dst, http.NoBody, 0, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the copy may fail after 200 OK is sent, the error is in the body then
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("s3 copy %s to %s failed: %s %s", src, dst, result.Code, result.Message)
+	}
+	return nil
+}
+
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var infos []ObjectInfo
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	for {
+		resp, err := s.send(ctx, http.MethodGet, s.bucketURL(query), nil, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			IsTruncated           bool
+			NextContinuationToken string
+			Contents              []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			infos = append(infos, ObjectInfo{Key: object.Key, ContentLength: object.Size, LastModified: object.LastModified})
+		}
+		if !result.IsTruncated {
+			return infos, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// signRequest adds the Authorization header, host and all headers of req are signed
+func (s *S3Storage) signRequest(req *http.Request, payloadHash string, t time.Time) {
+	t = t.UTC()
+	req.Header.Set("X-Amz-Date", t.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := s.scope(t)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.accessKey, scope, signedHeaders, s.signature(t, scope, canonicalRequest)))
+}
+
+// presignURL signs u by query parameters, host and headers are signed
+func (s *S3Storage) presignURL(method string, u *url.URL, headers map[string]string, t time.Time, expire time.Duration) string {
+	t = t.UTC()
+	scope := s.scope(t)
+
+	signed := map[string]string{"host": u.Host}
+	for k, v := range headers {
+		signed[k] = v
+	}
+	names := make([]string, 0, len(signed))
+	for k := range signed {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + signed[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", t.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expire/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedBody,
+	}, "\n")
+
+	presigned := *u
+	presigned.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + s.signature(t, scope, canonicalRequest)
+	return presigned.String()
+}
+
+func (s *S3Storage) scope(t time.Time) string {
+	return fmt.Sprintf("%s/%s/s3/aws4_request", t.Format(s3DateFormat), s.region)
+}
+
+func (s *S3Storage) signature(t time.Time, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, t.Format(s3TimeFormat), scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), t.Format(s3DateFormat))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery sorts the query by key and escapes it as SigV4 requires, spaces are %20 instead of +
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(query))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape escapes all characters except the unreserved ones of RFC 3986
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
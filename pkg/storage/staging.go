@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"mime/multipart"
+	"strings"
+)
+
+// StagingPrefix is the prefix of the keys which files are uploaded to before the database is committed
+const StagingPrefix = "staging/"
+
+// StagingKey returns a new staging key for key, which is like staging/{random}/{key}
+func StagingKey(key string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return StagingPrefix + hex.EncodeToString(b) + "/" + key
+}
+
+// PromotedKey returns the key which the staging key is promoted to
+func PromotedKey(stagingKey string) (string, bool) {
+	rest, ok := strings.CutPrefix(stagingKey, StagingPrefix)
+	if !ok {
+		return "", false
+	}
+	_, key, ok := strings.Cut(rest, "/")
+	return key, ok && key != ""
+}
+
+// StageFile uploads the file to a staging key of key and returns the staging key
+func StageFile(ctx context.Context, s Storage, key string, file *multipart.FileHeader) (string, error) {
+	stagingKey := StagingKey(key)
+	if err := PutFile(ctx, s, stagingKey, file); err != nil {
+		return "", err
+	}
+	return stagingKey, nil
+}
+
+// Promote moves the staging object to the key it's staged for
+func Promote(ctx context.Context, s Storage, stagingKey string) error {
+	key, ok := PromotedKey(stagingKey)
+	if !ok {
+		return ErrInvalidKey
+	}
+	if err := s.Copy(ctx, stagingKey, key); err != nil {
+		return err
+	}
+	return s.Delete(ctx, stagingKey)
+}
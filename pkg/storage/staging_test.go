@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPromote(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir(), "http://localhost:3333", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := "2023秋/web/uid/resume.pdf"
+	stagingKey := StagingKey(key)
+	if !strings.HasPrefix(stagingKey, StagingPrefix) {
+		t.Fatalf("StagingKey() = %s", stagingKey)
+	}
+	if got, ok := PromotedKey(stagingKey); !ok || got != key {
+		t.Fatalf("PromotedKey() = %s, %v, want %s", got, ok, key)
+	}
+	for _, invalid := range []string{key, StagingPrefix, StagingPrefix + "abc", StagingPrefix + "abc/"} {
+		if _, ok := PromotedKey(invalid); ok {
+			t.Errorf("PromotedKey(%q) is ok", invalid)
+		}
+	}
+
+	if err = s.Put(ctx, stagingKey, strings.NewReader("resume"), 6, "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	staged, err := s.List(ctx, StagingPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 1 || staged[0].Key != stagingKey {
+		t.Fatalf("List() = %+v, want %s", staged, stagingKey)
+	}
+
+	if err = Promote(ctx, s, stagingKey); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := s.Stat(ctx, key); err != nil || info.ContentLength != 6 {
+		t.Errorf("Stat() of promoted file = %+v, %v", info, err)
+	}
+	if _, err = s.Stat(ctx, stagingKey); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat() of staged file error = %v, want ErrNotFound", err)
+	}
+	if staged, _ = s.List(ctx, StagingPrefix); len(staged) != 0 {
+		t.Errorf("List() after Promote() = %+v", staged)
+	}
+	if files, _ := s.List(ctx, "2023秋/"); len(files) != 1 || files[0].Key != key {
+		t.Errorf("List() of recruitment = %+v", files)
+	}
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"time"
+)
+
+// Object is the content of an object, Body must be closed by the caller
+type Object struct {
+	Body          io.ReadCloser
+	ContentLength int64
+	ContentType   string
+}
+
+// ObjectInfo is the metadata of an object
+type ObjectInfo struct {
+	Key           string
+	ContentLength int64
+	ContentType   string
+	LastModified  time.Time
+}
+
+// Storage stores the files such as resumes and answers by key,
+// keys are slash separated paths like 2023秋/web/uid/resume.pdf
+type Storage interface {
+	// Put saves the object, an existing object with the same key is overwritten.
+	// size is -1 if unknown
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound if the object doesn't exist
+	Get(ctx context.Context, key string) (*Object, error)
+	// Presign returns an url to download the object without credentials, which expires after expire
+	Presign(ctx context.Context, key string, expire time.Duration) (string, error)
+	// PresignPut returns an url to upload the object by PUT without credentials, which expires after expire.
+	// The request must have the Content-Type and Content-Length given here
+	PresignPut(ctx context.Context, key string, contentType string, size int64, expire time.Duration) (string, error)
+	// Delete doesn't return error if the object doesn't exist
+	Delete(ctx context.Context, key string) error
+	// Stat returns ErrNotFound if the object doesn't exist
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Copy copies the object of src to dst, an existing object of dst is overwritten
+	Copy(ctx context.Context, src, dst string) error
+	// List returns all the objects whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+const (
+	DriverCOS   = "cos"
+	DriverLocal = "local"
+	DriverS3    = "s3"
+)
+
+var ErrNotFound = errors.New("the file doesn't exist")
+
+// PutFile saves the uploaded file into s
+func PutFile(ctx context.Context, s Storage, key string, file *multipart.FileHeader) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return s.Put(ctx, key, src, file.Size, file.Header.Get("Content-Type"))
+}
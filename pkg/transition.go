@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Action is an operation on an application, recorded in its events
+type Action string
+
+const (
+	ActionSetStep Action = "setStep"
+	ActionReject  Action = "reject"
+	ActionAbandon Action = "abandon"
+	ActionRestore Action = "restore"
+)
+
+var (
+	ErrStepMismatch       = errors.New("the step doesn't match")
+	ErrStepNotInPipeline  = errors.New("the step is not in the recruitment")
+	ErrSameStep           = errors.New("the application is already at this step")
+	ErrSkipNotAllowed     = errors.New("only quick pass application can skip steps")
+	ErrRollbackNotAllowed = errors.New("only admin can move application back")
+	ErrApplicationClosed  = errors.New("the application has already been abandoned/rejected")
+	ErrAlreadyRejected    = errors.New("the application has already been rejected")
+	ErrAlreadyAbandoned   = errors.New("the application has already been abandoned")
+	ErrAlreadyPassed      = errors.New("the application has already passed")
+	ErrNotClosed          = errors.New("the application is neither abandoned nor rejected")
+	ErrRestoreNotAllowed  = errors.New("only admin can restore abandoned application")
+)
+
+// TransitionError explains why a transition of application is refused,
+// use errors.Is to check the reason
+type TransitionError struct {
+	Aid    string
+	Action Action
+	From   Step
+	To     Step
+	Err    error
+}
+
+func (e *TransitionError) Error() string {
+	if e.Action == ActionSetStep {
+		return fmt.Sprintf("can't move application %s from %s to %s: %s", e.Aid, e.From, e.To, e.Err)
+	}
+	return fmt.Sprintf("can't %s application %s at %s: %s", e.Action, e.Aid, e.From, e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// CheckTransition is the state machine of application.
+// An application moves forward one rank each time, skipping is only allowed for quick pass application,
+// moving back is only allowed for admin. Online and offline interviews share a rank, so switching between them is allowed.
+// Abandoned/rejected application can't move until it's restored, and only admin can restore abandoned application.
+func CheckTransition(app *Application, pipeline Pipeline, action Action, from, to Step, operator *Operator) error {
+	refuse := func(err error) error {
+		return &TransitionError{Aid: app.Uid, Action: action, From: from, To: to, Err: err}
+	}
+
+	switch action {
+	case ActionSetStep:
+		if app.Abandoned || app.Rejected {
+			return refuse(ErrApplicationClosed)
+		}
+		if app.Step != from {
+			return refuse(ErrStepMismatch)
+		}
+		fromRank, ok := pipeline.Rank(from)
+		if !ok {
+			return refuse(ErrStepNotInPipeline)
+		}
+		toRank, ok := pipeline.Rank(to)
+		if !ok {
+			return refuse(ErrStepNotInPipeline)
+		}
+		switch {
+		case from == to:
+			return refuse(ErrSameStep)
+		case toRank > fromRank+1 && !app.IsQuick:
+			return refuse(ErrSkipNotAllowed)
+		case toRank < fromRank && !operator.IsAdmin():
+			return refuse(ErrRollbackNotAllowed)
+		}
+	case ActionReject:
+		if app.Rejected {
+			return refuse(ErrAlreadyRejected)
+		}
+		if app.Abandoned {
+			return refuse(ErrApplicationClosed)
+		}
+		if app.Step == Pass {
+			return refuse(ErrAlreadyPassed)
+		}
+	case ActionAbandon:
+		if app.Abandoned {
+			return refuse(ErrAlreadyAbandoned)
+		}
+		if app.Rejected {
+			return refuse(ErrApplicationClosed)
+		}
+	case ActionRestore:
+		if !app.Abandoned && !app.Rejected {
+			return refuse(ErrNotClosed)
+		}
+		if app.Abandoned && !operator.IsAdmin() {
+			return refuse(ErrRestoreNotAllowed)
+		}
+	default:
+		return fmt.Errorf("action %s is invalid", action)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	member := &Operator{UID: "member", Role: MemberRole}
+	admin := &Operator{UID: "admin", Role: Admin}
+	app := &Application{Common: Common{Uid: "aid"}, Step: WrittenTest}
+
+	cases := []struct {
+		name     string
+		action   Action
+		from, to Step
+		operator *Operator
+		want     error
+	}{
+		{"forward", ActionSetStep, WrittenTest, GroupTimeSelection, member, nil},
+		{"skip", ActionSetStep, WrittenTest, Pass, member, ErrSkipNotAllowed},
+		{"mismatch", ActionSetStep, SignUp, WrittenTest, member, ErrStepMismatch},
+		{"back by member", ActionSetStep, WrittenTest, SignUp, member, ErrRollbackNotAllowed},
+		{"back by admin", ActionSetStep, WrittenTest, SignUp, admin, nil},
+		{"reject", ActionReject, WrittenTest, "", member, nil},
+		{"restore", ActionRestore, WrittenTest, "", member, ErrNotClosed},
+	}
+	for _, c := range cases {
+		err := CheckTransition(app, DefaultPipeline, c.action, c.from, c.to, c.operator)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: expect %v, got %v", c.name, c.want, err)
+		}
+	}
+
+	quick := &Application{Common: Common{Uid: "quick"}, Step: WrittenTest, IsQuick: true}
+	if err := CheckTransition(quick, DefaultPipeline, ActionSetStep, WrittenTest, Pass, member); err != nil {
+		t.Errorf("quick pass application should skip steps, got %v", err)
+	}
+
+	abandoned := &Application{Common: Common{Uid: "abandoned"}, Step: WrittenTest, Abandoned: true}
+	if err := CheckTransition(abandoned, DefaultPipeline, ActionRestore, WrittenTest, "", member); !errors.Is(err, ErrRestoreNotAllowed) {
+		t.Errorf("member should not restore abandoned application, got %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package pkg
 
 import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"mime/multipart"
+	"strings"
 	"time"
 )
 
@@ -24,6 +28,7 @@ type UserDetail struct {
 	JoinTime    string   `json:"join_time"`
 	Groups      []string `json:"groups"`
 	LarkUnionID string   `json:"lark_union_id"`
+	Placeholder bool     `json:"placeholder,omitempty"` // sso failed to return the user, only uid is set
 }
 
 type UserDetailResp struct {
@@ -43,6 +48,9 @@ type Recruitment struct {
 	End             time.Time `gorm:"not null" json:"end"`
 	StressTestStart time.Time `gorm:"column:stressTestStart" json:"stress_test_start"`
 	StressTestEnd   time.Time `gorm:"column:stressTestEnd" json:"stress_test_end"`
+	Steps           Pipeline  `gorm:"column:steps;type:jsonb" json:"steps"` // null means DefaultPipeline
+	// BlindSteps are evaluated blind, members can't see others' comments made at these steps until they comment
+	BlindSteps []Step `gorm:"column:blindSteps;serializer:json;type:jsonb" json:"blind_steps"`
 
 	Statistics   map[string]int `gorm:"-" json:"statistics"`
 	GroupDetails map[string]int `gorm:"-" json:"group_details"`
@@ -54,6 +62,14 @@ func (r Recruitment) TableName() string {
 	return "recruitments"
 }
 
+// GetPipeline get the steps applications of the recruitment go through
+func (r Recruitment) GetPipeline() Pipeline {
+	if len(r.Steps) == 0 {
+		return DefaultPipeline
+	}
+	return r.Steps
+}
+
 func (r Recruitment) GetInterviews(name Group) []Interview {
 	reInterviews := make([]Interview, 0)
 	for _, interview := range r.Interviews {
@@ -64,20 +80,114 @@ func (r Recruitment) GetInterviews(name Group) []Interview {
 	return reInterviews
 }
 
+// Pipeline is the ordered steps of applications in a recruitment.
+// Online and offline interviews of the same stage share a rank, like StepRanks.
+type Pipeline []Step
+
+// Rank get the rank of step in the pipeline, starting from 1
+func (p Pipeline) Rank(step Step) (int, bool) {
+	rank := 0
+	for i, s := range p {
+		if i == 0 || StepRanks[s] != StepRanks[p[i-1]] {
+			rank++
+		}
+		if s == step {
+			return rank, true
+		}
+	}
+	return 0, false
+}
+
+// Value store the pipeline as json
+func (p Pipeline) Value() (driver.Value, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(p)
+	return string(bytes), err
+}
+
+func (p *Pipeline) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	}
+	return fmt.Errorf("can't scan %T into pipeline", value)
+}
+
+func (p Pipeline) Contains(step Step) bool {
+	_, ok := p.Rank(step)
+	return ok
+}
+
+// Validate check the pipeline starts with SignUp, ends with Pass,
+// and keeps the order of StepRanks without duplicated steps
+func (p Pipeline) Validate() error {
+	if len(p) < 2 || p[0] != SignUp || p[len(p)-1] != Pass {
+		return fmt.Errorf("steps should start with %s and end with %s", SignUp, Pass)
+	}
+	for i, step := range p {
+		rank, ok := StepRanks[step]
+		if !ok {
+			return fmt.Errorf("step %s is invalid", step)
+		}
+		if i > 0 && rank < StepRanks[p[i-1]] {
+			return fmt.Errorf("step %s can't be after %s", step, p[i-1])
+		}
+		for _, s := range p[:i] {
+			if s == step {
+				return fmt.Errorf("step %s is duplicated", step)
+			}
+		}
+	}
+	return nil
+}
+
 type CreateRecOpts struct {
 	Name      string    `json:"name" binding:"required"`
 	Beginning time.Time `json:"beginning" binding:"required"`
 	Deadline  time.Time `json:"deadline" binding:"required"`
 	End       time.Time `json:"end" binding:"required"`
+	Steps     Pipeline  `json:"steps"` // use DefaultPipeline if empty
 }
 
 func (r *CreateRecOpts) Validate() error {
 	if r.Beginning.After(r.Deadline) || r.Deadline.After(r.End) {
 		return errors.New("time set up wrong")
 	}
+	if len(r.Steps) != 0 {
+		if err := r.Steps.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+type SetRecStepsOpts struct {
+	Rid string
+
+	Steps Pipeline `json:"steps" binding:"required"`
+}
+
+func (opts *SetRecStepsOpts) Validate() error {
+	if opts.Rid == "" {
+		return errors.New("recruitment id is null")
+	}
+	return opts.Steps.Validate()
+}
+
+type SetRecBlindStepsOpts struct {
+	Rid string `uri:"rid" binding:"required"`
+
+	Steps []Step `json:"steps"` // empty to turn off blind evaluation
+	All   bool   `json:"all"`   // all steps of the recruitment, steps is ignored
+}
+
 type UpdateRecOpts struct {
 	Rid       string    `json:"rid"`
 	Name      string    `json:"name"`
@@ -97,6 +207,56 @@ type GetRecOpts struct {
 	Rid string `uri:"rid" binding:"required"`
 }
 
+type GetRecAnalyticsOpts struct {
+	Rid     string `uri:"rid" binding:"required"`
+	Compare *int   `form:"compare" binding:"omitempty,min=0,max=10"` // number of previous recruitments to compare, 3 by default
+}
+
+func (opts *GetRecAnalyticsOpts) Validate() (err error) {
+	if opts.Compare == nil {
+		compare := 3
+		opts.Compare = &compare
+	}
+	return
+}
+
+// RecruitmentAnalytics is the report of how applications go through the recruitment
+type RecruitmentAnalytics struct {
+	Rid       string `json:"rid"`
+	Name      string `json:"name"`
+	Total     int    `json:"total"`
+	Passed    int    `json:"passed"`
+	Abandoned int    `json:"abandoned"`
+	Rejected  int    `json:"rejected"`
+
+	Funnel       []FunnelStage           `json:"funnel"`
+	GroupFunnels map[Group][]FunnelStage `json:"group_funnels,omitempty"`
+	Grades       []AnalyticsBreakdown    `json:"grades,omitempty"`
+	Institutes   []AnalyticsBreakdown    `json:"institutes,omitempty"`
+	Previous     []RecruitmentAnalytics  `json:"previous,omitempty"` // only the summary and funnel of previous recruitments
+}
+
+// FunnelStage is the steps sharing a rank in the pipeline, such as online and offline interviews
+type FunnelStage struct {
+	Steps      []Step   `json:"steps"`
+	Reached    int      `json:"reached"`    // applications got to this stage or beyond
+	Current    int      `json:"current"`    // applications staying at this stage
+	Abandoned  int      `json:"abandoned"`  // applications abandoned at this stage
+	Rejected   int      `json:"rejected"`   // applications rejected at this stage
+	Conversion *float64 `json:"conversion"` // reached of the next stage / reached of this stage, null for the last stage or nobody reached
+	// median time applications spent in this stage before moving on, null if nobody has left it
+	MedianSeconds *int64 `json:"median_seconds"`
+}
+
+type AnalyticsBreakdown struct {
+	Key       string  `json:"key"`
+	Total     int     `json:"total"`
+	Passed    int     `json:"passed"`
+	Abandoned int     `json:"abandoned"`
+	Rejected  int     `json:"rejected"`
+	PassRate  float64 `json:"pass_rate"`
+}
+
 type SetStressTestTimeOpts struct {
 	Rid string
 
@@ -151,34 +311,129 @@ func (opts *DownloadRecruitmentFileOpts) Validate() error {
 // uniqueIndex(CandidateID,RecruitmentID)
 type Application struct {
 	Common
-	Grade                       string      `gorm:"not null" json:"grade"` //pkg.Grade
-	Institute                   string      `gorm:"not null" json:"institute"`
-	Major                       string      `gorm:"not null" json:"major"`
-	Rank                        string      `gorm:"not null" json:"rank"`
-	Group                       Group       `gorm:"not null" json:"group"` //pkg.Group
-	Intro                       string      `gorm:"not null" json:"intro"`
-	IsQuick                     bool        `gorm:"column:isQuick;not null" json:"is_quick"`
-	Referrer                    string      `json:"referrer"`
-	Resume                      string      `json:"resume"`
-	Answer                      string      `json:"answer"`
-	Abandoned                   bool        `gorm:"not null; default false" json:"abandoned"`
-	Rejected                    bool        `gorm:"not null; default false" json:"rejected"`
-	Step                        Step        `gorm:"not null" json:"step"`                                                                          //pkg.Step
-	CandidateID                 string      `gorm:"column:candidateId;type:uuid;uniqueIndex:UQ_CandidateID_RecruitmentID" json:"candidate_id"`     //manytoone
-	RecruitmentID               string      `gorm:"column:recruitmentId;type:uuid;uniqueIndex:UQ_CandidateID_RecruitmentID" json:"recruitment_id"` //manytoone
-	InterviewAllocationsGroupId string      `gorm:"column:interviewAllocationsGroupId;type:uuid;default:NULL" json:"interview_allocations_group_id"`
-	InterviewAllocationsTeamId  string      `gorm:"column:interviewAllocationsTeamId;type:uuid;default:NULL" json:"interview_allocations_team_id"`
-	InterviewAllocationsGroup   Interview   `gorm:"foreignKey:InterviewAllocationsGroupId" json:"interview_allocations_group"`
-	InterviewAllocationsTeam    Interview   `gorm:"foreignKey:InterviewAllocationsTeamId" json:"interview_allocations_team"`
-	UserDetail                  *UserDetail `gorm:"-" json:"user_detail"`                                                                                     // get from sso
-	InterviewSelections         []Interview `gorm:"many2many:interview_selections;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"interview_selections"` //manytomany
-	Comments                    []Comment   `gorm:"foreignKey:ApplicationID;references:Uid;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"comments"`    //onetomany
+	Grade                       string          `gorm:"not null" json:"grade"` //pkg.Grade
+	Institute                   string          `gorm:"not null" json:"institute"`
+	Major                       string          `gorm:"not null" json:"major"`
+	Rank                        string          `gorm:"not null" json:"rank"`
+	Group                       Group           `gorm:"not null" json:"group"` //pkg.Group
+	Intro                       string          `gorm:"not null" json:"intro"`
+	IsQuick                     bool            `gorm:"column:isQuick;not null" json:"is_quick"`
+	Referrer                    string          `json:"referrer"`
+	Resume                      string          `json:"resume"`
+	Answer                      string          `json:"answer"`
+	Abandoned                   bool            `gorm:"not null; default false" json:"abandoned"`
+	Rejected                    bool            `gorm:"not null; default false" json:"rejected"`
+	Step                        Step            `gorm:"not null" json:"step"`                                                                          //pkg.Step
+	CandidateID                 string          `gorm:"column:candidateId;type:uuid;uniqueIndex:UQ_CandidateID_RecruitmentID" json:"candidate_id"`     //manytoone
+	RecruitmentID               string          `gorm:"column:recruitmentId;type:uuid;uniqueIndex:UQ_CandidateID_RecruitmentID" json:"recruitment_id"` //manytoone
+	InterviewAllocationsGroupId string          `gorm:"column:interviewAllocationsGroupId;type:uuid;default:NULL" json:"interview_allocations_group_id"`
+	InterviewAllocationsTeamId  string          `gorm:"column:interviewAllocationsTeamId;type:uuid;default:NULL" json:"interview_allocations_team_id"`
+	InterviewAllocationsGroup   Interview       `gorm:"foreignKey:InterviewAllocationsGroupId" json:"interview_allocations_group"`
+	InterviewAllocationsTeam    Interview       `gorm:"foreignKey:InterviewAllocationsTeamId" json:"interview_allocations_team"`
+	UserDetail                  *UserDetail     `gorm:"-" json:"user_detail"`                                                                                     // get from sso
+	InterviewSelections         []Interview     `gorm:"many2many:interview_selections;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"interview_selections"` //manytomany
+	Comments                    []Comment       `gorm:"foreignKey:ApplicationID;references:Uid;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"comments"`    //onetomany
+	ResumeVersions              []ResumeVersion `gorm:"foreignKey:ApplicationID;references:Uid;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`           //onetomany
+	AverageEvaluation           *float64        `gorm:"column:averageEvaluation;->;-:migration" json:"average_evaluation,omitempty"`                              // only filled by listing
+	SearchScore                 *float64        `gorm:"column:searchScore;->;-:migration" json:"search_score,omitempty"`                                          // only filled by search
+	Score                       *float64        `gorm:"column:score;->;-:migration" json:"score,omitempty"`                                                       // average rubric score of comments
 }
 
 func (a Application) TableName() string {
 	return "applications"
 }
 
+// ApplicationEvent records who changed the application and how,
+// it has no foreign key so that the history is kept after the application is deleted
+type ApplicationEvent struct {
+	Common
+	ApplicationID string `gorm:"column:applicationId;type:uuid;not null;index" json:"application_id"`
+	OperatorID    string `gorm:"column:operatorId;type:uuid" json:"operator_id"`
+	Action        string `gorm:"not null" json:"action"`
+	From          Step   `gorm:"column:fromStep" json:"from"`
+	To            Step   `gorm:"column:toStep" json:"to"`
+	Reason        string `json:"reason"`
+}
+
+func (e ApplicationEvent) TableName() string {
+	return "application_events"
+}
+
+// ResumeVersion is a resume the candidate has uploaded, Application.Resume is the key of the latest version.
+// CreatedAt is when it was uploaded
+type ResumeVersion struct {
+	Common
+	ApplicationID string `gorm:"column:applicationId;type:uuid;not null;uniqueIndex:UQ_ApplicationID_Version" json:"application_id"`
+	Version       int    `gorm:"not null;uniqueIndex:UQ_ApplicationID_Version" json:"version"` // starts from 1
+	Key           string `gorm:"not null" json:"key"`
+	Hash          string `json:"hash"` // hex of sha256, empty for the resumes uploaded before versioning
+	Size          int64  `json:"size"`
+	ContentType   string `gorm:"column:contentType" json:"content_type"`
+}
+
+func (v ResumeVersion) TableName() string {
+	return "resume_versions"
+}
+
+// DeadlineVersion returns the latest version uploaded before deadline, 0 if there is none.
+// versions are sorted by version
+func DeadlineVersion(versions []ResumeVersion, deadline time.Time) int {
+	version := 0
+	for _, v := range versions {
+		if v.CreatedAt.After(deadline) {
+			break
+		}
+		version = v.Version
+	}
+	return version
+}
+
+// SMSMessage is the outbox of notifications sent by sms or email, it's written before sending
+// and the worker keeps retrying until it's sent or max attempts are reached
+type SMSMessage struct {
+	Common
+	ApplicationID    string          `gorm:"column:applicationId;type:uuid;index;default:NULL" json:"application_id"` // empty for verification code
+	OperatorID       string          `gorm:"column:operatorId;type:uuid;default:NULL" json:"operator_id"`
+	Channel          NotifyChannel   `gorm:"not null;default:sms" json:"channel"`
+	Phone            string          `gorm:"not null" json:"phone"` // for sms
+	Email            string          `json:"email"`                 // for email
+	Subject          string          `json:"subject"`               // for email
+	Template         SMSTemplateType `gorm:"column:template" json:"template"`
+	TemplateID       uint            `gorm:"column:templateId;not null" json:"template_id"` // for sms, email template is resolved by alias when sending
+	Params           []string        `gorm:"serializer:json;type:jsonb" json:"params"`
+	Status           SMSStatus       `gorm:"not null;index" json:"status"`
+	Attempts         int             `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt    time.Time       `gorm:"column:nextAttemptAt;not null;index" json:"next_attempt_at"`
+	SentAt           *time.Time      `gorm:"column:sentAt" json:"sent_at"`
+	ProviderStatus   int             `gorm:"column:providerStatus" json:"provider_status"` // http or grpc status code of open platform
+	ProviderResponse string          `gorm:"column:providerResponse" json:"provider_response"`
+	LastError        string          `gorm:"column:lastError" json:"last_error"`
+}
+
+// Notification is the content of a message chosen by template, the same content is sent by every channel
+type Notification struct {
+	Template SMSTemplateType
+	Params   []string
+}
+
+func (m SMSMessage) TableName() string {
+	return "sms_messages"
+}
+
+type GetSMSMessagesOpts struct {
+	Aid string `form:"aid" binding:"required"`
+}
+
+// Operator is the user who operates on applications
+type Operator struct {
+	UID  string
+	Role Role
+}
+
+func (o *Operator) IsAdmin() bool {
+	return o != nil && o.Role == Admin
+}
+
 type CreateAppOpts struct {
 	Grade         string `form:"grade" json:"grade" binding:"required"`
 	Institute     string `form:"institute" json:"institute" binding:"required"`
@@ -231,8 +486,185 @@ func (opts *UpdateAppOpts) Validate() (err error) {
 type SetAppStepOpts struct {
 	Aid string
 
-	From Step `json:"from" binding:"required"`
-	To   Step `json:"to" binding:"required"`
+	From   Step   `json:"from" binding:"required"`
+	To     Step   `json:"to" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+const (
+	SortByCreatedAt  = "created_at"
+	SortByEvaluation = "evaluation" // average evaluation of comments, applications without comment are the last
+	SortByScore      = "score"      // average rubric score of comments, applications without score are the last
+)
+
+type ListApplicationsOpts struct {
+	Rid         string       `uri:"rid" binding:"required"`
+	Groups      []Group      `form:"group"`
+	Steps       []Step       `form:"step"`
+	Rejected    *bool        `form:"rejected"`
+	Abandoned   *bool        `form:"abandoned"`
+	Grades      []string     `form:"grade"`
+	Institute   string       `form:"institute"`
+	IsQuick     *bool        `form:"is_quick"`
+	HasResume   *bool        `form:"has_resume"`
+	Evaluations []Evaluation `form:"evaluation"` // has any comment of the evaluations
+	Sort        string       `form:"sort"`       // created_at (default), evaluation or score
+	Desc        bool         `form:"desc"`
+	Cursor      string       `form:"cursor"` // next_cursor of the last page
+	Limit       int          `form:"limit" binding:"omitempty,min=1,max=200"`
+}
+
+func (opts *ListApplicationsOpts) Validate() (err error) {
+	if opts.Sort == "" {
+		opts.Sort = SortByCreatedAt
+	}
+	if opts.Sort != SortByCreatedAt && opts.Sort != SortByEvaluation && opts.Sort != SortByScore {
+		return fmt.Errorf("request param error, sort %s is invalid", opts.Sort)
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 50
+	}
+	for _, step := range opts.Steps {
+		if _, ok := StepRanks[step]; !ok {
+			return fmt.Errorf("request param error, step %s is invalid", step)
+		}
+	}
+	for _, group := range opts.Groups {
+		if _, ok := GroupMap[group]; !ok {
+			return fmt.Errorf("request param error, group %s is invalid", group)
+		}
+	}
+	return
+}
+
+type SearchApplicationsOpts struct {
+	Rid   string `uri:"rid" binding:"required"`
+	Q     string `form:"q" binding:"required"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+func (opts *SearchApplicationsOpts) Validate() (err error) {
+	opts.Q = strings.TrimSpace(opts.Q)
+	if opts.Q == "" {
+		return errors.New("request param error, q is empty")
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 20
+	}
+	return
+}
+
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+)
+
+type ExportApplicationsOpts struct {
+	Rid    string `uri:"rid" binding:"required"`
+	Format string `form:"format"` // csv by default
+	Group  Group  `form:"group"`  // all groups member is in by default
+}
+
+func (opts *ExportApplicationsOpts) Validate() (err error) {
+	if opts.Format == "" {
+		opts.Format = ExportCSV
+	}
+	if opts.Format != ExportCSV && opts.Format != ExportXLSX {
+		return fmt.Errorf("request param error, format %s is invalid", opts.Format)
+	}
+	if opts.Group != "" {
+		if _, ok := GroupMap[opts.Group]; !ok {
+			return fmt.Errorf("request param error, group %s is invalid", opts.Group)
+		}
+	}
+	return
+}
+
+type ListApplicationsResp struct {
+	Applications []Application `json:"applications"`
+	NextCursor   string        `json:"next_cursor,omitempty"` // empty if it's the last page
+}
+
+// ApplicationCursor is the sort key and uid of the last application in a page,
+// the next page starts after it in the same order
+type ApplicationCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  string `json:"k"`
+	Uid  string `json:"u"`
+}
+
+func (c *ApplicationCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeApplicationCursor(cursor string, sort string, desc bool) (*ApplicationCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("request param error, cursor is invalid")
+	}
+	c := &ApplicationCursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.Uid == "" {
+		return nil, errors.New("request param error, cursor is invalid")
+	}
+	if c.Sort != sort || c.Desc != desc {
+		return nil, errors.New("request param error, cursor doesn't match the sort")
+	}
+	return c, nil
+}
+
+type SetAppsStepOpts struct {
+	Aids   []string `json:"aids" binding:"required"`
+	From   Step     `json:"from" binding:"required"`
+	To     Step     `json:"to" binding:"required"`
+	Reason string   `json:"reason"`
+}
+
+func (opts *SetAppsStepOpts) Validate() (err error) {
+	if len(opts.Aids) == 0 {
+		return errors.New("request body error, aids is nil")
+	}
+	opts.Aids = uniqueAids(opts.Aids)
+	if _, ok := StepRanks[opts.From]; !ok {
+		return fmt.Errorf("request body error, from step %s set wrong", opts.From)
+	}
+	if _, ok := StepRanks[opts.To]; !ok {
+		return fmt.Errorf("request body error, to step %s set wrong", opts.To)
+	}
+	return
+}
+
+type RejectAppsOpts struct {
+	Aids   []string `json:"aids" binding:"required"`
+	Reason string   `json:"reason"`
+}
+
+func (opts *RejectAppsOpts) Validate() (err error) {
+	if len(opts.Aids) == 0 {
+		return errors.New("request body error, aids is nil")
+	}
+	opts.Aids = uniqueAids(opts.Aids)
+	return
+}
+
+// uniqueAids remove the repeated aids, otherwise the repeated application fails the transition
+// after it's applied once and the whole batch is rolled back
+func uniqueAids(aids []string) []string {
+	seen := make(map[string]bool, len(aids))
+	unique := aids[:0]
+	for _, aid := range aids {
+		if !seen[aid] {
+			seen[aid] = true
+			unique = append(unique, aid)
+		}
+	}
+	return unique
+}
+
+// ChangeAppStatusOpts is the optional body of reject/abandon/restore application
+type ChangeAppStatusOpts struct {
+	Reason string `json:"reason"`
 }
 
 func (opts *SetAppStepOpts) Validate() (err error) {
@@ -270,6 +702,58 @@ func (opts *SetAppInterviewTimeOpts) Validate() (err error) {
 	return
 }
 
+// RescheduleRequest is filed by candidate who can't attend the allocated interview,
+// member of the group approves it by choosing one of the alternatives or denies it
+type RescheduleRequest struct {
+	Common
+	ApplicationID       string           `gorm:"column:applicationId;type:uuid;not null;index" json:"application_id"`
+	InterviewType       GroupOrTeam      `gorm:"column:interviewType;not null" json:"interview_type"`
+	InterviewID         string           `gorm:"column:interviewId;type:uuid;not null" json:"interview_id"` // the allocated interview
+	Reason              string           `gorm:"not null" json:"reason"`
+	Alternatives        []Interview      `gorm:"many2many:reschedule_alternatives;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"alternatives"`
+	Status              RescheduleStatus `gorm:"not null;index" json:"status"`
+	ReviewerID          string           `gorm:"column:reviewerId;type:uuid;default:NULL" json:"reviewer_id"`
+	ReviewNote          string           `gorm:"column:reviewNote" json:"review_note"`
+	ApprovedInterviewID string           `gorm:"column:approvedInterviewId;type:uuid;default:NULL" json:"approved_interview_id"`
+}
+
+func (r RescheduleRequest) TableName() string {
+	return "reschedule_requests"
+}
+
+type CreateRescheduleOpts struct {
+	Aid           string
+	InterviewType GroupOrTeam
+
+	Reason string   `json:"reason" binding:"required"`
+	Iids   []string `json:"iids" binding:"required"` // alternative interviews
+}
+
+func (opts *CreateRescheduleOpts) Validate() (err error) {
+	if opts.InterviewType != InGroup && opts.InterviewType != InTeam {
+		return fmt.Errorf("request param rerror, type should be group/team")
+	}
+	if opts.Aid == "" {
+		return errors.New("request param error, application id is nil")
+	}
+	if len(opts.Iids) == 0 {
+		return errors.New("request body error, len of interview ids is 0")
+	}
+	return
+}
+
+type GetReschedulesOpts struct {
+	Rid    string           `uri:"rid" binding:"required"`
+	Status RescheduleStatus `form:"status"` // pending by default
+}
+
+type ReviewRescheduleOpts struct {
+	ID string `uri:"id" binding:"required"`
+
+	InterviewId string `json:"interview_id"` // required by approval, must be one of the alternatives
+	Note        string `json:"note"`
+}
+
 type UploadAnswerFileOpts struct {
 	Aid  string                `uri:"aid" binding:"required"`
 	Type Step                  `uri:"type" binding:"required"`
@@ -286,6 +770,53 @@ func (opts *UploadAnswerFileOpts) Validate() error {
 	return nil
 }
 
+type CreateUploadOpts struct {
+	Aid string `uri:"aid" binding:"required"`
+
+	Type        UploadType `json:"type"` // resume or answer
+	Filename    string     `json:"filename"`
+	ContentType string     `json:"content_type"`
+	Size        int64      `json:"size"` // bytes
+}
+
+// Validate checks the type and filename, the content type and size are checked by the policy of upload
+func (opts *CreateUploadOpts) Validate() error {
+	if opts.Type != UploadResume && opts.Type != UploadAnswer {
+		return fmt.Errorf("request param error, upload type %s is invalid", opts.Type)
+	}
+	if opts.Filename == "" {
+		return errors.New("request param error, filename is nil")
+	}
+	return nil
+}
+
+// PresignedUpload is the url to upload file directly to storage,
+// the file is attached to application after the upload is confirmed with Key
+type PresignedUpload struct {
+	Key       string            `json:"key"`
+	URL       string            `json:"url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"` // must be sent as is
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+type ConfirmUploadOpts struct {
+	Aid string `uri:"aid" binding:"required"`
+
+	Type UploadType `json:"type"` // resume or answer
+	Key  string     `json:"key"`  // got from PresignedUpload
+}
+
+func (opts *ConfirmUploadOpts) Validate() error {
+	if opts.Type != UploadResume && opts.Type != UploadAnswer {
+		return fmt.Errorf("request param error, upload type %s is invalid", opts.Type)
+	}
+	if opts.Key == "" {
+		return errors.New("request param error, key is nil")
+	}
+	return nil
+}
+
 type DownloadAnswerFileOpts struct {
 	Aid  string `uri:"aid" binding:"required"`
 	Type Step   `uri:"type" binding:"required"`
@@ -335,6 +866,16 @@ type GetResumeUrlResp struct {
 	ResumeUrl string `json:"resume_url"`
 }
 
+type GetResumeOpts struct {
+	Aid     string `uri:"aid" binding:"required"`
+	Version int    `form:"version" binding:"omitempty,min=0"` // the latest version if 0
+}
+
+type GetResumeVersionsResp struct {
+	Versions        []ResumeVersion `json:"versions"`
+	DeadlineVersion int             `json:"deadline_version"` // the version candidate submitted before the deadline of recruitment, 0 if none
+}
+
 type Interview struct {
 	Common
 	Date          time.Time     `json:"date" gorm:"not null;uniqueIndex:interviews_all"`
@@ -344,15 +885,74 @@ type Interview struct {
 	End           time.Time     `json:"end" gorm:"not null;"`
 	RecruitmentID string        `json:"recruitment_id" gorm:"not null;column:recruitmentId;type:uuid;uniqueIndex:interviews_all"` //manytoone
 	Applications  []Application `json:"applications,omitempty" gorm:"many2many:interview_selections"`                             //manytomany
-	// remove select number and slot number
-	// SelectNumber  int           `json:"select_number" gorm:"not null;column:selectNumber;default:0"`
-	// SlotNumber    int           `json:"slot_number" gorm:"column:slotNumber;not null"`
+	SelectNumber  int           `json:"select_number" gorm:"not null;column:selectNumber;default:0"`
+	SlotNumber    int           `json:"slot_number" gorm:"column:slotNumber;not null;default:0"` // 0 means no limit (interviews created before slot number existed)
+	Remaining     *int          `json:"remaining,omitempty" gorm:"-"`                            // seats left, nil means no limit
+	// InterviewerNumber is how many interviewers the interview needs, 0 means no limit
+	InterviewerNumber int           `json:"interviewer_number" gorm:"column:interviewerNumber;not null;default:0"`
+	Interviewers      []Interviewer `json:"interviewers,omitempty" gorm:"foreignKey:InterviewID;references:Uid;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;"`
+	Allocated         *int          `json:"allocated,omitempty" gorm:"-"`    // candidates allocated, only filled for panels
+	Unstaffed         bool          `json:"unstaffed,omitempty" gorm:"-"`    // candidates are allocated but nobody interviews them
+	Understaffed      bool          `json:"understaffed,omitempty" gorm:"-"` // candidates are allocated but interviewers are fewer than InterviewerNumber
 }
 
 func (c Interview) TableName() string {
 	return "interviews"
 }
 
+// Interviewer is a member signed up to run the interview
+type Interviewer struct {
+	Common
+	InterviewID string `gorm:"column:interviewId;type:uuid;not null;uniqueIndex:UQ_Interviewer_InterviewID_MemberID" json:"interview_id"`
+	MemberID    string `gorm:"column:memberId;type:uuid;not null;uniqueIndex:UQ_Interviewer_InterviewID_MemberID;index" json:"member_id"`
+	MemberName  string `gorm:"column:memberName" json:"member_name"`
+}
+
+func (i Interviewer) TableName() string {
+	return "interviewers"
+}
+
+// MyInterview is an interview the member runs with the candidates allocated to it
+type MyInterview struct {
+	Interview
+	Candidates []Application `json:"candidates"`
+}
+
+// InterviewCancellation keeps the deleted interview, so that calendar feeds can cancel its event
+type InterviewCancellation struct {
+	Common
+	InterviewID   string    `gorm:"column:interviewId;type:uuid;not null;index" json:"interview_id"`
+	RecruitmentID string    `gorm:"column:recruitmentId;type:uuid;not null;index" json:"recruitment_id"`
+	Name          Group     `gorm:"not null" json:"name"`
+	Start         time.Time `gorm:"not null" json:"start"`
+	End           time.Time `gorm:"not null" json:"end"`
+}
+
+func (c InterviewCancellation) TableName() string {
+	return "interview_cancellations"
+}
+
+// SetStaffing fill the number of allocated candidates and whether the interview needs more interviewers,
+// Interviewers must be loaded
+func (c *Interview) SetStaffing(allocated int) {
+	c.Allocated = &allocated
+	c.Unstaffed = allocated > 0 && len(c.Interviewers) == 0
+	c.Understaffed = allocated > 0 && len(c.Interviewers) < c.InterviewerNumber
+}
+
+// SetRemaining fill the seats left of the interview
+func (c *Interview) SetRemaining() {
+	if c.SlotNumber <= 0 {
+		c.Remaining = nil
+		return
+	}
+	remaining := c.SlotNumber - c.SelectNumber
+	if remaining < 0 {
+		remaining = 0
+	}
+	c.Remaining = &remaining
+}
+
 type GetInterviewsOpts struct {
 	Rid  string `uri:"rid" binding:"required"`
 	Name Group  `uri:"name" binding:"required"`
@@ -367,10 +967,13 @@ func (opts *GetInterviewsOpts) Validate() (err error) {
 }
 
 type CreateInterviewOpts struct {
-	Date   time.Time `json:"date" form:"date" binding:"required"`
-	Period Period    `json:"period" form:"period" binding:"required" `
-	Start  time.Time `json:"start" form:"start" binding:"required"`
-	End    time.Time `json:"end" form:"end" binding:"required"`
+	Date       time.Time `json:"date" form:"date" binding:"required"`
+	Period     Period    `json:"period" form:"period" binding:"required" `
+	Start      time.Time `json:"start" form:"start" binding:"required"`
+	End        time.Time `json:"end" form:"end" binding:"required"`
+	SlotNumber int       `json:"slot_number" form:"slot_number" binding:"required,min=1"` // the capacity of the interview
+	// InterviewerNumber is how many interviewers the interview needs, 0 means no limit
+	InterviewerNumber int `json:"interviewer_number" form:"interviewer_number" binding:"min=0"`
 }
 
 type DeleteInterviewOpts struct {
@@ -378,11 +981,55 @@ type DeleteInterviewOpts struct {
 }
 
 type UpdateInterviewOpts struct {
-	Uid    string    `json:"uid" form:"uid"`
-	Date   time.Time `json:"date" form:"date" binding:"required"`
-	Period Period    `json:"period" form:"period" binding:"required" `
-	Start  time.Time `json:"start" form:"start" binding:"required"`
-	End    time.Time `json:"end" form:"end" binding:"required"`
+	Uid        string    `json:"uid" form:"uid"`
+	Date       time.Time `json:"date" form:"date" binding:"required"`
+	Period     Period    `json:"period" form:"period" binding:"required" `
+	Start      time.Time `json:"start" form:"start" binding:"required"`
+	End        time.Time `json:"end" form:"end" binding:"required"`
+	SlotNumber int       `json:"slot_number" form:"slot_number" binding:"required,min=1"`
+	// InterviewerNumber is how many interviewers the interview needs, 0 means no limit
+	InterviewerNumber int `json:"interviewer_number" form:"interviewer_number" binding:"min=0"`
+}
+
+type AllocateInterviewsOpts struct {
+	Rid  string `uri:"rid" binding:"required"`
+	Name Group  `uri:"name" binding:"required"`
+
+	Capacity   int  `json:"capacity" binding:"min=0"` // candidates per interview slot, only used by interviews without slot number
+	Reallocate bool `json:"reallocate"`               // drop the existing allocations and allocate everyone again
+}
+
+func (opts *AllocateInterviewsOpts) Validate() (err error) {
+	if _, ok := GroupMap[opts.Name]; !ok {
+		err = fmt.Errorf("request param wrong, name set wrong")
+		return
+	}
+	return nil
+}
+
+// InterviewType tells whether the interviews named opts.Name are group or team interviews
+func (opts *AllocateInterviewsOpts) InterviewType() GroupOrTeam {
+	if opts.Name == Unique {
+		return InTeam
+	}
+	return InGroup
+}
+
+type InterviewAllocation struct {
+	Aid         string `json:"aid"`
+	CandidateID string `json:"candidate_id"`
+	Iid         string `json:"iid"`
+}
+
+type UnallocatedApplication struct {
+	Aid         string `json:"aid"`
+	CandidateID string `json:"candidate_id"`
+	Reason      string `json:"reason"`
+}
+
+type AllocateInterviewsResp struct {
+	Allocations []InterviewAllocation    `json:"allocations"`
+	Unallocated []UnallocatedApplication `json:"unallocated"`
 }
 
 type Comment struct {
@@ -392,24 +1039,250 @@ type Comment struct {
 	MemberName    string     `gorm:"column:memberName;" json:"member_name"`
 	Content       string     `gorm:"column:content;not null" json:"content"`
 	Evaluation    Evaluation `gorm:"column:evaluation;type:int;not null" json:"evaluation"`
+	// Scores is the score of each criterion in the rubric, keyed by criterion key
+	Scores map[string]int `gorm:"serializer:json;type:jsonb" json:"scores,omitempty"`
+	// Score is the weighted score of Scores by the rubric when the comment is created, 0-100
+	Score      *float64          `gorm:"column:score" json:"score,omitempty"`
+	ParentID   string            `gorm:"column:parentId;type:uuid;default:NULL;index" json:"parent_id,omitempty"` // the comment replied to
+	Step       Step              `gorm:"column:step" json:"step,omitempty"`                                       // step of application when it's commented
+	Visibility CommentVisibility `gorm:"not null;default:members" json:"visibility"`
+	EditedAt   *time.Time        `gorm:"column:editedAt" json:"edited_at,omitempty"`
+
+	// replies are kept after the comment replied to is deleted
+	Replies   []Comment         `gorm:"foreignKey:ParentID;references:Uid;constraint:OnDelete:SET NULL,OnUpdate:CASCADE;" json:"-"`
+	Revisions []CommentRevision `gorm:"foreignKey:CommentID;references:Uid;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
 }
 
 func (c Comment) TableName() string {
 	return "comments"
 }
 
+// CommentViewer is the member who comments are filtered for
+type CommentViewer struct {
+	UID     string
+	Groups  []string
+	IsAdmin bool
+}
+
+// VisibleTo check if the member can see the comment of application in the group,
+// the author can always see their own comment
+func (c *Comment) VisibleTo(uid string, groups []string, isAdmin bool, group Group) bool {
+	if isAdmin || c.MemberID == uid {
+		return true
+	}
+	switch c.Visibility {
+	case CommentVisibleToGroup:
+		for _, g := range groups {
+			if Group(g) == group {
+				return true
+			}
+		}
+		return false
+	case CommentVisibleToAdmins:
+		return false
+	default:
+		return true
+	}
+}
+
+// FilterBlindComments remove the comments others made at blind steps of application which the member hasn't commented at,
+// unless the step is revealed. Comments made before blind evaluation was turned on don't have step, they are kept.
+func FilterBlindComments(comments []Comment, uid string, blindSteps []Step, revealed map[Step]bool) []Comment {
+	blind := make(map[Step]bool)
+	for _, step := range blindSteps {
+		if !revealed[step] {
+			blind[step] = true
+		}
+	}
+	submitted := make(map[Step]bool)
+	for _, comment := range comments {
+		if comment.MemberID == uid {
+			submitted[comment.Step] = true
+		}
+	}
+
+	filtered := make([]Comment, 0, len(comments))
+	for _, comment := range comments {
+		if comment.MemberID != uid && blind[comment.Step] && !submitted[comment.Step] {
+			continue
+		}
+		filtered = append(filtered, comment)
+	}
+	return filtered
+}
+
+// AverageScore returns the average rubric score of comments, nil if none of them is scored
+func AverageScore(comments []Comment) *float64 {
+	var sum float64
+	var count int
+	for _, comment := range comments {
+		if comment.Score != nil {
+			sum += *comment.Score
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	average := sum / float64(count)
+	return &average
+}
+
+// CommentRevision is the comment before it's edited
+type CommentRevision struct {
+	Common
+	CommentID  string            `gorm:"column:commentId;type:uuid;not null;index" json:"comment_id"`
+	EditorID   string            `gorm:"column:editorId;type:uuid" json:"editor_id"`
+	Content    string            `gorm:"column:content;not null" json:"content"`
+	Evaluation Evaluation        `gorm:"column:evaluation;type:int;not null" json:"evaluation"`
+	Scores     map[string]int    `gorm:"serializer:json;type:jsonb" json:"scores,omitempty"`
+	Score      *float64          `gorm:"column:score" json:"score,omitempty"`
+	Visibility CommentVisibility `gorm:"not null" json:"visibility"`
+}
+
+func (r CommentRevision) TableName() string {
+	return "comment_revisions"
+}
+
+// EvaluationRound is the interviewers assigned to evaluate application at a blind step,
+// comments of the step are revealed to everyone after all of them have commented
+type EvaluationRound struct {
+	Common
+	ApplicationID string     `gorm:"column:applicationId;type:uuid;not null;uniqueIndex:UQ_EvaluationRound_ApplicationID_Step" json:"application_id"`
+	Step          Step       `gorm:"not null;uniqueIndex:UQ_EvaluationRound_ApplicationID_Step" json:"step"`
+	Evaluators    []string   `gorm:"serializer:json;type:jsonb" json:"evaluators"` // uid of members
+	RevealedAt    *time.Time `gorm:"column:revealedAt" json:"revealed_at"`
+	RevealerID    string     `gorm:"column:revealerId;type:uuid;default:NULL" json:"revealer_id"`
+
+	Submitted []string `gorm:"-" json:"submitted"` // evaluators who have commented at the step
+}
+
+func (r EvaluationRound) TableName() string {
+	return "evaluation_rounds"
+}
+
+type SetEvaluatorsOpts struct {
+	Aid  string `uri:"aid" binding:"required"`
+	Step Step   `uri:"step" binding:"required"`
+
+	Evaluators []string `json:"evaluators" binding:"required,min=1"`
+}
+
+type RevealCommentsOpts struct {
+	Aid  string `uri:"aid" binding:"required"`
+	Step Step   `uri:"step" binding:"required"`
+}
+
 type CreateCommentOpts struct {
 	MemberID   string `json:"member_id"`
 	MemberName string `json:"member_name"`
 
-	ApplicationID string     `json:"application_id" binding:"required"`
-	Content       string     `json:"content"`
-	Evaluation    Evaluation `json:"evaluation"`
+	ApplicationID string         `json:"application_id" binding:"required"`
+	Content       string         `json:"content"`
+	Evaluation    Evaluation     `json:"evaluation"`
+	Scores        map[string]int `json:"scores"` // scores against the rubric of application's group
+	Score         *float64       `json:"-"`      // weighted by the rubric
+	ParentID      string         `json:"parent_id"`
+	Step          Step           `json:"-"` // step of application
+	// Visibility is members by default, a reply takes the visibility of the comment replied to by default
+	Visibility CommentVisibility `json:"visibility"`
 }
 
 func (opts *CreateCommentOpts) Validate() (err error) {
-	if opts.Evaluation != Good && opts.Evaluation != Normal && opts.Evaluation != Bad && opts.Content == "" {
-		err = fmt.Errorf("request body error, evaluation and content is nil")
+	if opts.Evaluation != Good && opts.Evaluation != Normal && opts.Evaluation != Bad && opts.Content == "" && len(opts.Scores) == 0 {
+		return fmt.Errorf("request body error, evaluation, content and scores is nil")
+	}
+	return validateCommentVisibility(opts.Visibility)
+}
+
+// UpdateCommentOpts replace the content, evaluation, scores and visibility of comment, the fields not given are unchanged
+type UpdateCommentOpts struct {
+	Cid string `uri:"cid" binding:"required"`
+
+	Content    string            `json:"content"`    // unchanged if empty
+	Evaluation Evaluation        `json:"evaluation"` // unchanged if empty
+	Scores     map[string]int    `json:"scores"`     // unchanged if empty
+	Score      *float64          `json:"-"`
+	Visibility CommentVisibility `json:"visibility"` // unchanged if empty
+}
+
+func (opts *UpdateCommentOpts) Validate() (err error) {
+	if opts.Evaluation != Good && opts.Evaluation != Normal && opts.Evaluation != Bad && opts.Content == "" && len(opts.Scores) == 0 {
+		return fmt.Errorf("request body error, evaluation, content and scores is nil")
+	}
+	return validateCommentVisibility(opts.Visibility)
+}
+
+func validateCommentVisibility(visibility CommentVisibility) error {
+	switch visibility {
+	case "", CommentVisibleToGroup, CommentVisibleToMembers, CommentVisibleToAdmins:
+		return nil
+	}
+	return fmt.Errorf("request body error, visibility %s is invalid", visibility)
+}
+
+// Rubric is how a group scores applications in a recruitment
+type Rubric struct {
+	Common
+	RecruitmentID string            `gorm:"column:recruitmentId;type:uuid;not null;uniqueIndex:UQ_Rubric_RecruitmentID_Group" json:"recruitment_id"`
+	Group         Group             `gorm:"not null;uniqueIndex:UQ_Rubric_RecruitmentID_Group" json:"group"`
+	Criteria      []RubricCriterion `gorm:"serializer:json;type:jsonb;not null" json:"criteria"`
+}
+
+func (r Rubric) TableName() string {
+	return "rubrics"
+}
+
+type RubricCriterion struct {
+	Key    string  `json:"key" binding:"required"` // such as algorithm, scores are keyed by it
+	Name   string  `json:"name" binding:"required"`
+	Min    int     `json:"min"`
+	Max    int     `json:"max" binding:"required"`
+	Weight float64 `json:"weight" binding:"required"`
+}
+
+// Score check scores cover all the criteria in range, and get the weighted score scaled to 0-100
+func (r Rubric) Score(scores map[string]int) (float64, error) {
+	if len(scores) != len(r.Criteria) {
+		return 0, fmt.Errorf("request body error, scores of %d criteria are required", len(r.Criteria))
+	}
+	var sum, weights float64
+	for _, criterion := range r.Criteria {
+		score, ok := scores[criterion.Key]
+		if !ok {
+			return 0, fmt.Errorf("request body error, score of %s is required", criterion.Key)
+		}
+		if score < criterion.Min || score > criterion.Max {
+			return 0, fmt.Errorf("request body error, score of %s should be between %d and %d", criterion.Key, criterion.Min, criterion.Max)
+		}
+		sum += criterion.Weight * float64(score-criterion.Min) / float64(criterion.Max-criterion.Min)
+		weights += criterion.Weight
+	}
+	return sum / weights * 100, nil
+}
+
+type SetRubricOpts struct {
+	Rid      string            `uri:"rid" binding:"required"`
+	Group    Group             `uri:"group" binding:"required"`
+	Criteria []RubricCriterion `json:"criteria" binding:"required,min=1,dive"`
+}
+
+func (opts *SetRubricOpts) Validate() (err error) {
+	if _, ok := GroupMap[opts.Group]; !ok {
+		return fmt.Errorf("request param error, group %s is invalid", opts.Group)
+	}
+	keys := make(map[string]struct{})
+	for _, criterion := range opts.Criteria {
+		if _, ok := keys[criterion.Key]; ok {
+			return fmt.Errorf("request body error, criterion %s is duplicated", criterion.Key)
+		}
+		keys[criterion.Key] = struct{}{}
+		if criterion.Min >= criterion.Max {
+			return fmt.Errorf("request body error, min of %s should be less than max", criterion.Key)
+		}
+		if criterion.Weight <= 0 {
+			return fmt.Errorf("request body error, weight of %s should be positive", criterion.Key)
+		}
 	}
 	return
 }
@@ -423,6 +1296,8 @@ type SendSMSOpts struct {
 	MeetingId string   `json:"meeting_id"`
 	Rest      string   `json:"rest"`
 	Aids      []string `json:"aids"` // the applications will be sent sms
+	// Channels the notification is sent by, sms by default
+	Channels []NotifyChannel `json:"channels"`
 }
 
 func (opts *SendSMSOpts) Validate() (err error) {
@@ -445,11 +1320,25 @@ func (opts *SendSMSOpts) Validate() (err error) {
 		err = fmt.Errorf("request body error, aids is nil")
 		return
 	}
-	if _, ok := EnToZhStepMap[opts.Next]; !ok {
+	if len(opts.Channels) == 0 {
+		opts.Channels = []NotifyChannel{ChannelSMS}
+	}
+	for _, channel := range opts.Channels {
+		if channel != ChannelSMS && channel != ChannelEmail {
+			err = fmt.Errorf("request body error, channel %s is invalid", channel)
+			return
+		}
+	}
+	return
+}
+
+// ValidateSteps check current and next step are in the pipeline of the recruitment
+func (opts *SendSMSOpts) ValidateSteps(pipeline Pipeline) (err error) {
+	if !pipeline.Contains(opts.Next) {
 		err = fmt.Errorf("request body error, next is invalid")
 		return
 	}
-	if _, ok := EnToZhStepMap[opts.Current]; !ok {
+	if !pipeline.Contains(opts.Current) {
 		err = fmt.Errorf("request body error, current is invalid")
 		return
 	}
@@ -0,0 +1,199 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	summerCamp := Pipeline{SignUp, WrittenTest, GroupTimeSelection, GroupInterview, OnlineGroupInterview, Pass}
+	if err := summerCamp.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if rank, _ := summerCamp.Rank(OnlineGroupInterview); rank != 4 {
+		t.Fatalf("expect rank of %s is 4, got %d", OnlineGroupInterview, rank)
+	}
+	if rank, _ := summerCamp.Rank(Pass); rank != 5 {
+		t.Fatalf("expect rank of %s is 5, got %d", Pass, rank)
+	}
+	if summerCamp.Contains(StressTest) {
+		t.Fatalf("%s should not be in the pipeline", StressTest)
+	}
+
+	if err := (Pipeline{SignUp, GroupInterview, WrittenTest, Pass}).Validate(); err == nil {
+		t.Fatal("expect steps out of order to be invalid")
+	}
+	if err := (Pipeline{WrittenTest, Pass}).Validate(); err == nil {
+		t.Fatal("expect steps without SignUp to be invalid")
+	}
+}
+
+func TestApplicationCursor(t *testing.T) {
+	cursor := &ApplicationCursor{Sort: SortByEvaluation, Desc: true, Key: "1.5", Uid: "c549b215-3b27-4978-b537-2228b81848fb"}
+	decoded, err := DecodeApplicationCursor(cursor.Encode(), SortByEvaluation, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded != *cursor {
+		t.Fatalf("expect %v, got %v", cursor, decoded)
+	}
+
+	if _, err := DecodeApplicationCursor(cursor.Encode(), SortByCreatedAt, true); err == nil {
+		t.Fatal("expect cursor of another sort to be invalid")
+	}
+	if _, err := DecodeApplicationCursor(cursor.Encode(), SortByEvaluation, false); err == nil {
+		t.Fatal("expect cursor of another direction to be invalid")
+	}
+	if _, err := DecodeApplicationCursor("not a cursor", SortByEvaluation, true); err == nil {
+		t.Fatal("expect malformed cursor to be invalid")
+	}
+}
+
+func TestRubricScore(t *testing.T) {
+	rubric := Rubric{Criteria: []RubricCriterion{
+		{Key: "algorithm", Min: 1, Max: 5, Weight: 2},
+		{Key: "communication", Min: 1, Max: 5, Weight: 1},
+	}}
+	score, err := rubric.Score(map[string]int{"algorithm": 5, "communication": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (2*1 + 1*0.25) / 3
+	if score != 75 {
+		t.Fatalf("expect score 75, got %v", score)
+	}
+
+	if _, err = rubric.Score(map[string]int{"algorithm": 5}); err == nil {
+		t.Fatal("expect scores missing a criterion to be invalid")
+	}
+	if _, err = rubric.Score(map[string]int{"algorithm": 6, "communication": 2}); err == nil {
+		t.Fatal("expect score out of range to be invalid")
+	}
+	if _, err = rubric.Score(map[string]int{"algorithm": 5, "depth": 2}); err == nil {
+		t.Fatal("expect score of unknown criterion to be invalid")
+	}
+}
+
+func TestCommentVisibleTo(t *testing.T) {
+	cases := []struct {
+		visibility CommentVisibility
+		uid        string
+		groups     []string
+		isAdmin    bool
+		want       bool
+	}{
+		{CommentVisibleToMembers, "member", []string{"lab"}, false, true},
+		{CommentVisibleToGroup, "member", []string{"web"}, false, true},
+		{CommentVisibleToGroup, "member", []string{"lab"}, false, false},
+		{CommentVisibleToAdmins, "member", []string{"web"}, false, false},
+		{CommentVisibleToAdmins, "admin", nil, true, true},
+		{CommentVisibleToAdmins, "author", nil, false, true},
+	}
+	for _, c := range cases {
+		comment := &Comment{MemberID: "author", Visibility: c.visibility}
+		if got := comment.VisibleTo(c.uid, c.groups, c.isAdmin, Web); got != c.want {
+			t.Fatalf("expect %s comment visible to %s in %v to be %v", c.visibility, c.uid, c.groups, c.want)
+		}
+	}
+}
+
+func TestFilterBlindComments(t *testing.T) {
+	comments := []Comment{
+		{Common: Common{Uid: "old"}, MemberID: "other"},
+		{Common: Common{Uid: "written"}, MemberID: "other", Step: WrittenTest},
+		{Common: Common{Uid: "interview"}, MemberID: "other", Step: GroupInterview},
+		{Common: Common{Uid: "mine"}, MemberID: "me", Step: WrittenTest},
+		{Common: Common{Uid: "stress"}, MemberID: "other", Step: StressTest},
+	}
+	blindSteps := []Step{WrittenTest, GroupInterview, StressTest}
+
+	filtered := FilterBlindComments(comments, "me", blindSteps, map[Step]bool{StressTest: true})
+	var uids []string
+	for _, comment := range filtered {
+		uids = append(uids, comment.Uid)
+	}
+	// hidden: others' comment at GroupInterview, which I haven't commented at and isn't revealed
+	want := []string{"old", "written", "mine", "stress"}
+	if len(uids) != len(want) {
+		t.Fatalf("expect %v, got %v", want, uids)
+	}
+	for i := range want {
+		if uids[i] != want[i] {
+			t.Fatalf("expect %v, got %v", want, uids)
+		}
+	}
+}
+
+func TestDeadlineVersion(t *testing.T) {
+	deadline := time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)
+	versions := []ResumeVersion{
+		{Common: Common{CreatedAt: deadline.Add(-48 * time.Hour)}, Version: 1},
+		{Common: Common{CreatedAt: deadline}, Version: 2},
+		{Common: Common{CreatedAt: deadline.Add(time.Hour)}, Version: 3},
+	}
+	if version := DeadlineVersion(versions, deadline); version != 2 {
+		t.Fatalf("expect version 2 at the deadline, got %d", version)
+	}
+	if version := DeadlineVersion(versions[2:], deadline); version != 0 {
+		t.Fatalf("expect no version at the deadline, got %d", version)
+	}
+}
+
+func TestAverageScore(t *testing.T) {
+	if score := AverageScore([]Comment{{}}); score != nil {
+		t.Fatalf("expect no score without scored comments, got %v", *score)
+	}
+	high, low := 90.0, 60.0
+	score := AverageScore([]Comment{{Score: &high}, {}, {Score: &low}})
+	if score == nil || *score != 75 {
+		t.Fatalf("expect average score 75, got %v", score)
+	}
+}
+
+func TestInterviewSetStaffing(t *testing.T) {
+	interview := &Interview{InterviewerNumber: 3, Interviewers: []Interviewer{{MemberID: "m1"}}}
+	interview.SetStaffing(2)
+	if interview.Unstaffed || !interview.Understaffed {
+		t.Fatalf("expect interview with 1 of 3 interviewers to be understaffed only, got unstaffed %v understaffed %v", interview.Unstaffed, interview.Understaffed)
+	}
+
+	interview.Interviewers = nil
+	interview.SetStaffing(2)
+	if !interview.Unstaffed || !interview.Understaffed {
+		t.Fatal("expect interview without interviewers to be unstaffed and understaffed")
+	}
+
+	interview.SetStaffing(0)
+	if interview.Unstaffed || interview.Understaffed {
+		t.Fatal("expect interview without candidates to need no interviewers")
+	}
+}
+
+func TestSetAppsStepOptsValidate(t *testing.T) {
+	opts := &SetAppsStepOpts{Aids: []string{"a", "b", "a", "c", "b"}, From: SignUp, To: WrittenTest}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(opts.Aids) != 3 || opts.Aids[0] != "a" || opts.Aids[1] != "b" || opts.Aids[2] != "c" {
+		t.Fatalf("expect repeated aids to be removed in order, got %v", opts.Aids)
+	}
+}
+
+func TestCreateUploadOptsValidate(t *testing.T) {
+	valid := CreateUploadOpts{Aid: "aid", Type: UploadResume, Filename: "resume.pdf", ContentType: "application/pdf", Size: 1 << 20}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expect valid opts, got %v", err)
+	}
+
+	cases := map[string]func(opts *CreateUploadOpts){
+		"unknown type":   func(opts *CreateUploadOpts) { opts.Type = "photo" },
+		"empty filename": func(opts *CreateUploadOpts) { opts.Filename = "" },
+	}
+	for name, modify := range cases {
+		opts := valid
+		modify(&opts)
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%s: expect error", name)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package upload
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const clamAVChunkSize = 64 << 10
+
+// ClamAVScanner scans files by the INSTREAM command of clamd
+type ClamAVScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner creates the scanner by the address of clamd like unix:///var/run/clamav/clamd.ctl or tcp://127.0.0.1:3310
+func NewClamAVScanner(addr string) (*ClamAVScanner, error) {
+	network, address, ok := strings.Cut(addr, "://")
+	if !ok || (network != "unix" && network != "tcp") || address == "" {
+		return nil, fmt.Errorf("clamav address %s is invalid", addr)
+	}
+	return &ClamAVScanner{network: network, address: address, timeout: 2 * time.Minute}, nil
+}
+
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader) (string, error) {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		return "", err
+	}
+
+	// the content is sent in chunks prefixed with their length, a zero length chunk ends the stream
+	if _, err = conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", err
+	}
+	buf := make([]byte, clamAVChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, errRead := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err = conn.Write(size); err != nil {
+				return "", err
+			}
+			if _, err = conn.Write(buf[:n]); err != nil {
+				return "", err
+			}
+		}
+		if errRead == io.EOF {
+			break
+		}
+		if errRead != nil {
+			return "", errRead
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err = conn.Write(size); err != nil {
+		return "", err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return parseClamAVReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamAVReply parses the reply like "stream: OK" or "stream: Eicar-Test-Signature FOUND"
+func parseClamAVReply(reply string) (string, error) {
+	_, result, ok := strings.Cut(reply, ": ")
+	switch {
+	case !ok:
+		return "", fmt.Errorf("clamav replies %q", reply)
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamav replies %q", reply)
+	}
+}
@@ -0,0 +1,87 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// serveClamAV accepts one INSTREAM command and replies FOUND if the content contains "EICAR"
+func serveClamAV(t *testing.T, l net.Listener) {
+	conn, err := l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil || command != "zINSTREAM\x00" {
+		t.Errorf("command = %q, %v", command, err)
+		return
+	}
+	var content bytes.Buffer
+	size := make([]byte, 4)
+	for {
+		if _, err = io.ReadFull(r, size); err != nil {
+			t.Error(err)
+			return
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+		if _, err = io.CopyN(&content, r, int64(n)); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if strings.Contains(content.String(), "EICAR") {
+		conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		return
+	}
+	conn.Write([]byte("stream: OK\x00"))
+}
+
+func TestClamAVScanner(t *testing.T) {
+	for content, want := range map[string]string{
+		strings.Repeat("clean", clamAVChunkSize): "",
+		"X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR":     "Eicar-Test-Signature",
+	} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go serveClamAV(t, l)
+
+		scanner, err := NewClamAVScanner("tcp://" + l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		virus, err := scanner.Scan(context.Background(), strings.NewReader(content))
+		l.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if virus != want {
+			t.Errorf("Scan() = %q, want %q", virus, want)
+		}
+	}
+}
+
+func TestParseClamAVReply(t *testing.T) {
+	if _, err := parseClamAVReply("INSTREAM size limit exceeded. ERROR"); err == nil {
+		t.Error("expect error for size limit")
+	}
+	if _, err := parseClamAVReply("stream: Can't allocate memory ERROR"); err == nil {
+		t.Error("expect error for clamd error")
+	}
+	if _, err := NewClamAVScanner("127.0.0.1:3310"); err == nil {
+		t.Error("expect error for address without network")
+	}
+}
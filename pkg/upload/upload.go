@@ -0,0 +1,270 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"UniqueRecruitmentBackend/pkg/storage"
+)
+
+// Purpose is what the file is uploaded for, each purpose has its own Policy
+type Purpose string
+
+const (
+	PurposeResume          Purpose = "resume"
+	PurposeAnswer          Purpose = "answer"
+	PurposeRecruitmentFile Purpose = "recruitment_file" // such as written test
+)
+
+const (
+	ContentTypePDF  = "application/pdf"
+	ContentTypeDOC  = "application/msword"
+	ContentTypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	ContentTypeZIP  = "application/zip"
+	ContentTypeRAR  = "application/x-rar-compressed"
+	ContentType7Z   = "application/x-7z-compressed"
+)
+
+// QuarantinePrefix is the prefix of the keys infected files are saved to
+const QuarantinePrefix = "quarantine/"
+
+const sniffLength = 512
+
+var extensions = map[string]string{
+	ContentTypePDF:  ".pdf",
+	ContentTypeDOC:  ".doc",
+	ContentTypeDOCX: ".docx",
+	ContentTypeZIP:  ".zip",
+	ContentTypeRAR:  ".rar",
+	ContentType7Z:   ".7z",
+}
+
+var (
+	ErrUnknownPurpose = errors.New("the purpose of upload is invalid")
+	ErrEmptyFile      = errors.New("the file is empty")
+	ErrFileTooLarge   = errors.New("the file is too large")
+	ErrContentType    = errors.New("the type of file is not allowed")
+	ErrInfected       = errors.New("the file is infected")
+)
+
+// Policy limits the files uploaded for a purpose
+type Policy struct {
+	MaxSize      int64    // bytes
+	ContentTypes []string // allowed types sniffed from the content
+}
+
+// DefaultPolicies are used when the size limits are not configured
+var DefaultPolicies = map[Purpose]Policy{
+	PurposeResume:          {MaxSize: 20 << 20, ContentTypes: []string{ContentTypePDF, ContentTypeDOC, ContentTypeDOCX}},
+	PurposeAnswer:          {MaxSize: 100 << 20, ContentTypes: []string{ContentTypePDF, ContentTypeDOCX, ContentTypeZIP, ContentTypeRAR, ContentType7Z}},
+	PurposeRecruitmentFile: {MaxSize: 50 << 20, ContentTypes: []string{ContentTypePDF}}, // saved as {type}.pdf
+}
+
+// Scanner scans the content of files for viruses
+type Scanner interface {
+	// Scan returns the name of virus found, empty if the content is clean
+	Scan(ctx context.Context, r io.Reader) (string, error)
+}
+
+// Validator checks the uploaded files by the policy of their purposes
+type Validator struct {
+	Policies   map[Purpose]Policy
+	Scanner    Scanner         // files are not scanned if nil
+	Quarantine storage.Storage // infected files are saved under QuarantinePrefix if not nil
+}
+
+// Result is the information of a valid file
+type Result struct {
+	ContentType string
+	Size        int64
+	Hash        string // hex of sha256
+}
+
+// Filename is the name which the file is saved as, the hash of content with the extension of its type.
+// The name given by user is never used in keys
+func (r *Result) Filename() string {
+	return r.Hash + extensions[r.ContentType]
+}
+
+// Check checks the content type and size declared by user before the file is uploaded
+func (v *Validator) Check(purpose Purpose, contentType string, size int64) error {
+	policy, ok := v.Policies[purpose]
+	if !ok {
+		return ErrUnknownPurpose
+	}
+	if size <= 0 {
+		return ErrEmptyFile
+	}
+	if size > policy.MaxSize {
+		return fmt.Errorf("%w, the max size is %d bytes", ErrFileTooLarge, policy.MaxSize)
+	}
+	if !allowed(policy, contentType) {
+		return fmt.Errorf("%w: %s", ErrContentType, contentType)
+	}
+	return nil
+}
+
+// Validate sniffs the type of f, checks its size and scans it. filename is only used to tell docx from zip.
+// Infected files are quarantined and ErrInfected is returned
+func (v *Validator) Validate(ctx context.Context, purpose Purpose, filename string, f io.ReadSeeker) (*Result, error) {
+	policy, ok := v.Policies[purpose]
+	if !ok {
+		return nil, ErrUnknownPurpose
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, ErrEmptyFile
+	}
+	result := &Result{ContentType: Sniff(head[:n], filename)}
+	if !allowed(policy, result.ContentType) {
+		return nil, fmt.Errorf("%w: %s", ErrContentType, result.ContentType)
+	}
+
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	result.Size, err = io.Copy(hash, io.LimitReader(f, policy.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if result.Size > policy.MaxSize {
+		return nil, fmt.Errorf("%w, the max size is %d bytes", ErrFileTooLarge, policy.MaxSize)
+	}
+	result.Hash = hex.EncodeToString(hash.Sum(nil))
+
+	// any zip can be named .docx, it's a zip unless it has the entries of a word document
+	if result.ContentType == ContentTypeDOCX && !isDOCX(f, result.Size) {
+		result.ContentType = ContentTypeZIP
+		if !allowed(policy, result.ContentType) {
+			return nil, fmt.Errorf("%w: %s", ErrContentType, result.ContentType)
+		}
+	}
+
+	if v.Scanner == nil {
+		return result, nil
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	virus, err := v.Scanner.Scan(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	if virus == "" {
+		return result, nil
+	}
+
+	v.quarantine(ctx, result, f)
+	return nil, fmt.Errorf("%w: %s", ErrInfected, virus)
+}
+
+// quarantine saves the infected file for review, failure is ignored as the file is refused anyway
+func (v *Validator) quarantine(ctx context.Context, result *Result, f io.ReadSeeker) {
+	if v.Quarantine == nil {
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	key := fmt.Sprintf("%s%s/%s", QuarantinePrefix, time.Now().Format("20060102"), result.Filename())
+	_ = v.Quarantine.Put(ctx, key, f, result.Size, result.ContentType)
+}
+
+// Sniff detects the content type by the first bytes of file. A zip named .docx is taken as docx,
+// Validate checks its entries later
+func Sniff(head []byte, filename string) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("7z\xBC\xAF\x27\x1C")):
+		return ContentType7Z
+	case bytes.HasPrefix(head, []byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1")):
+		// compound file of the legacy office formats
+		return ContentTypeDOC
+	}
+
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	// docx is a zip archive
+	if contentType == ContentTypeZIP && strings.EqualFold(path.Ext(filename), ".docx") {
+		return ContentTypeDOCX
+	}
+	return contentType
+}
+
+// isDOCX checks the zip archive has the content types and the document of word
+func isDOCX(f io.ReadSeeker, size int64) bool {
+	r, err := zip.NewReader(readerAt{f}, size)
+	if err != nil {
+		return false
+	}
+	var contentTypes, document bool
+	for _, file := range r.File {
+		switch {
+		case file.Name == "[Content_Types].xml":
+			contentTypes = true
+		case strings.HasPrefix(file.Name, "word/"):
+			document = true
+		}
+	}
+	return contentTypes && document
+}
+
+// readerAt reads the file at offsets for archive/zip
+type readerAt struct {
+	f io.ReadSeeker
+}
+
+func (r readerAt) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.f.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.f, p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// SanitizeFilename keeps letters, digits, dots, dashes and underscores of the base name
+func SanitizeFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	sanitized := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, filename)
+	sanitized = strings.TrimLeft(sanitized, ".")
+	if runes := []rune(sanitized); len(runes) > 100 {
+		sanitized = string(runes[len(runes)-100:])
+	}
+	if sanitized == "" {
+		return "file"
+	}
+	return sanitized
+}
+
+func allowed(policy Policy, contentType string) bool {
+	for _, t := range policy.ContentTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
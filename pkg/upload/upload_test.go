@@ -0,0 +1,184 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"UniqueRecruitmentBackend/pkg/storage"
+)
+
+var pdf = []byte("%PDF-1.7\n1 0 obj\n<<>>\nendobj\n%%EOF\n")
+
+type fakeScanner struct {
+	virus   string
+	scanned []byte
+}
+
+func (s *fakeScanner) Scan(ctx context.Context, r io.Reader) (string, error) {
+	var err error
+	s.scanned, err = io.ReadAll(r)
+	return s.virus, err
+}
+
+func TestSniff(t *testing.T) {
+	cases := []struct {
+		head     []byte
+		filename string
+		want     string
+	}{
+		{pdf, "resume.pdf", ContentTypePDF},
+		{pdf, "resume.exe", ContentTypePDF},
+		{[]byte("PK\x03\x04rest"), "answer.zip", ContentTypeZIP},
+		{[]byte("PK\x03\x04rest"), "resume.DOCX", ContentTypeDOCX},
+		{[]byte("Rar!\x1A\x07\x00rest"), "answer.rar", ContentTypeRAR},
+		{[]byte("7z\xBC\xAF\x27\x1Crest"), "answer.7z", ContentType7Z},
+		{[]byte("\xD0\xCF\x11\xE0\xA1\xB1\x1A\xE1rest"), "resume.doc", ContentTypeDOC},
+		{[]byte("<html><script>alert(1)</script>"), "resume.pdf", "text/html"},
+	}
+	for _, c := range cases {
+		if got := Sniff(c.head, c.filename); got != c.want {
+			t.Errorf("Sniff(%q, %s) = %s, want %s", c.head[:4], c.filename, got, c.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{Policies: map[Purpose]Policy{
+		PurposeResume: {MaxSize: int64(len(pdf)), ContentTypes: []string{ContentTypePDF}},
+	}}
+
+	result, err := v.Validate(ctx, PurposeResume, "../../resume.pdf", bytes.NewReader(pdf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256(pdf)
+	if want := hex.EncodeToString(hash[:]) + ".pdf"; result.Filename() != want || result.Size != int64(len(pdf)) {
+		t.Errorf("Validate() = %+v, want filename %s", result, want)
+	}
+
+	if _, err = v.Validate(ctx, PurposeResume, "resume.pdf", bytes.NewReader([]byte("<html></html>"))); !errors.Is(err, ErrContentType) {
+		t.Errorf("Validate() of html error = %v, want ErrContentType", err)
+	}
+	if _, err = v.Validate(ctx, PurposeResume, "resume.pdf", bytes.NewReader(append(pdf, ' '))); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Validate() of large file error = %v, want ErrFileTooLarge", err)
+	}
+	if _, err = v.Validate(ctx, PurposeResume, "resume.pdf", bytes.NewReader(nil)); !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("Validate() of empty file error = %v, want ErrEmptyFile", err)
+	}
+	if _, err = v.Validate(ctx, PurposeAnswer, "answer.pdf", bytes.NewReader(pdf)); !errors.Is(err, ErrUnknownPurpose) {
+		t.Errorf("Validate() of unknown purpose error = %v, want ErrUnknownPurpose", err)
+	}
+}
+
+func TestValidateDOCX(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{Policies: DefaultPolicies}
+
+	docx := zipOf(t, "[Content_Types].xml", "_rels/.rels", "word/document.xml")
+	result, err := v.Validate(ctx, PurposeResume, "resume.docx", bytes.NewReader(docx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ContentType != ContentTypeDOCX {
+		t.Errorf("Validate() content type = %s, want %s", result.ContentType, ContentTypeDOCX)
+	}
+
+	archive := zipOf(t, "answer/main.go")
+	if _, err = v.Validate(ctx, PurposeResume, "resume.docx", bytes.NewReader(archive)); !errors.Is(err, ErrContentType) {
+		t.Errorf("Validate() of zip named docx error = %v, want ErrContentType", err)
+	}
+	result, err = v.Validate(ctx, PurposeAnswer, "answer.docx", bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ContentType != ContentTypeZIP {
+		t.Errorf("Validate() content type = %s, want %s", result.ContentType, ContentTypeZIP)
+	}
+}
+
+func zipOf(t *testing.T, names ...string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidateScan(t *testing.T) {
+	ctx := context.Background()
+	quarantine, err := storage.NewLocalStorage(t.TempDir(), "http://localhost:3333", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := &fakeScanner{}
+	v := &Validator{Policies: DefaultPolicies, Scanner: scanner, Quarantine: quarantine}
+
+	if _, err = v.Validate(ctx, PurposeResume, "resume.pdf", bytes.NewReader(pdf)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(scanner.scanned, pdf) {
+		t.Errorf("scanned %q, want the whole file", scanner.scanned)
+	}
+
+	scanner.virus = "Eicar-Test-Signature"
+	if _, err = v.Validate(ctx, PurposeResume, "resume.pdf", bytes.NewReader(pdf)); !errors.Is(err, ErrInfected) || !strings.Contains(err.Error(), scanner.virus) {
+		t.Errorf("Validate() of infected file error = %v, want ErrInfected", err)
+	}
+	quarantined, err := quarantine.List(ctx, QuarantinePrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quarantined) != 1 || quarantined[0].ContentLength != int64(len(pdf)) {
+		t.Errorf("quarantined files = %+v", quarantined)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	v := &Validator{Policies: DefaultPolicies}
+	if err := v.Check(PurposeResume, ContentTypePDF, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Check(PurposeResume, ContentTypeZIP, 1<<20); !errors.Is(err, ErrContentType) {
+		t.Errorf("Check() of zip resume error = %v, want ErrContentType", err)
+	}
+	if err := v.Check(PurposeResume, ContentTypePDF, DefaultPolicies[PurposeResume].MaxSize+1); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("Check() of large file error = %v, want ErrFileTooLarge", err)
+	}
+	if err := v.Check(PurposeResume, ContentTypePDF, 0); !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("Check() of empty file error = %v, want ErrEmptyFile", err)
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := map[string]string{
+		"简历 v2.pdf":          "简历_v2.pdf",
+		"../../etc/passwd":   "passwd",
+		`C:\Users\me\cv.pdf`: "cv.pdf",
+		"..":                 "file",
+		".hidden":            "hidden",
+		"a<script>.pdf":      "a_script_.pdf",
+	}
+	for name, want := range cases {
+		if got := SanitizeFilename(name); got != want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
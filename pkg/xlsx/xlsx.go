@@ -0,0 +1,151 @@
+// Package xlsx writes a single sheet xlsx file row by row, so that large sheets are streamed
+// instead of being buffered. Cells are inline strings, no shared strings table is needed.
+package xlsx
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+)
+
+const (
+	contentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+</Types>`
+	rootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+	workbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+</Relationships>`
+	workbookHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`
+	workbookTail = `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	sheetHead    = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetTail = `</sheetData></worksheet>`
+)
+
+// Writer writes rows into the only sheet, Close must be called to finish the file
+type Writer struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	row    int
+	closed bool
+}
+
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	zw := zip.NewWriter(w)
+	var workbook []byte
+	workbook = append(workbook, workbookHead...)
+	workbook = append(workbook, escape(sheetName)...)
+	workbook = append(workbook, workbookTail...)
+
+	for _, part := range []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", []byte(contentTypes)},
+		{"_rels/.rels", []byte(rootRels)},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", []byte(workbookRels)},
+	} {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = f.Write(part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	// the sheet must be the last entry, zip entries can't be written concurrently
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	if _, err = sheet.WriteString(sheetHead); err != nil {
+		return nil, err
+	}
+	return &Writer{zw: zw, sheet: sheet}, nil
+}
+
+// WriteRow writes cells as inline strings
+func (w *Writer) WriteRow(cells []string) error {
+	if w.closed {
+		return errors.New("xlsx: write to closed writer")
+	}
+	w.row++
+	row := strconv.Itoa(w.row)
+	w.sheet.WriteString(`<row r="` + row + `">`)
+	for i, cell := range cells {
+		w.sheet.WriteString(`<c r="` + columnName(i) + row + `" t="inlineStr"><is><t xml:space="preserve">`)
+		w.sheet.WriteString(escape(cell))
+		w.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+// Flush sends the buffered rows to the underlying writer
+func (w *Writer) Flush() error {
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Flush()
+}
+
+// Close finishes the sheet and the zip, it doesn't close the underlying writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if _, err := w.sheet.WriteString(sheetTail); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// columnName converts 0 based index to A, B, ..., Z, AA, AB...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// escape escapes text for xml and drops the characters xml 1.0 doesn't allow
+func escape(s string) string {
+	var b []byte
+	for _, r := range s {
+		if r != '\t' && r != '\n' && r != '\r' && (r < 0x20 || r == 0xFFFE || r == 0xFFFF) {
+			continue
+		}
+		b = append(b, string(r)...)
+	}
+	var out bytesWriter
+	_ = xml.EscapeText(&out, b)
+	return string(out)
+}
+
+type bytesWriter []byte
+
+func (w *bytesWriter) Write(p []byte) (int, error) {
+	*w = append(*w, p...)
+	return len(p), nil
+}
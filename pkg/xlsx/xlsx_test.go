@@ -0,0 +1,66 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "2024秋季招新")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRow([]string{"姓名", "组别"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRow([]string{"<Tom & Jerry>", "web\x00"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+
+		// every part must be well-formed
+		decoder := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := decoder.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed: %v", f.Name, err)
+			}
+		}
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			sheet = string(data)
+		}
+	}
+
+	if !strings.Contains(sheet, `<c r="A2" t="inlineStr"><is><t xml:space="preserve">&lt;Tom &amp; Jerry&gt;</t></is></c>`) {
+		t.Fatalf("unexpected sheet: %s", sheet)
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Fatalf("expect column %d to be %s, got %s", i, want, got)
+		}
+	}
+}